@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cwxstat/go-pod-launch-run/pkg"
+	"github.com/spf13/cobra"
+)
+
+// cpCmd copies files between the local host and a pod
+var cpCmd = &cobra.Command{
+	Use:   "cp <src> <dst>",
+	Short: "Copy files and directories to and from a pod",
+	Long: `Copies a file or directory, recursively, between the local host and a
+container of a pod, like kubectl cp. The remote side is written as
+[namespace/]pod:path; the namespace defaults to --namespace.
+
+  gopl cp aws-cli-pod-x7k2q:/tmp/cpu.pprof ./cpu.pprof
+  gopl cp ./config tools/aws-cli-pod-x7k2q:/root/.aws
+
+The copy is sent as a tar archive over the exec channel, so the container
+needs tar. --container is used when given, otherwise the pod's default
+container. Archive entries that would land outside the local destination
+are refused.
+`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		src, dst := parseCopyPath(args[0]), parseCopyPath(args[1])
+		if (src.pod == "") == (dst.pod == "") {
+			return fmt.Errorf("exactly one of %q and %q must be a pod path, [namespace/]pod:path", args[0], args[1])
+		}
+		opts, err := launcherOptions()
+		if err != nil {
+			return err
+		}
+		remote := src
+		if remote.pod == "" {
+			remote = dst
+		}
+		if remote.path == "" {
+			return fmt.Errorf("missing path in the pod after %q", remote.pod+":")
+		}
+		if remote.namespace != "" {
+			opts = append(opts, pkg.WithNamespace(remote.namespace))
+		}
+		copyContainer := ""
+		if cmd.Flags().Changed("container") {
+			copyContainer = container
+		}
+
+		launcher := pkg.NewLauncher(opts...)
+		if src.pod != "" {
+			return launcher.CopyFromPod(cmd.Context(), src.pod, copyContainer, src.path, dst.path)
+		}
+		return launcher.CopyToPod(cmd.Context(), dst.pod, copyContainer, src.path, dst.path)
+	},
+}
+
+// copyPath is an argument of gopl cp. pod is empty for a local path.
+type copyPath struct {
+	namespace string
+	pod       string
+	path      string
+}
+
+// parseCopyPath splits [namespace/]pod:path. Arguments without a colon, or
+// that start with ".", "/" or "~", are local paths.
+func parseCopyPath(arg string) copyPath {
+	i := strings.Index(arg, ":")
+	if i <= 0 || strings.ContainsAny(arg[:1], "./~") {
+		return copyPath{path: arg}
+	}
+	p := copyPath{pod: arg[:i], path: arg[i+1:]}
+	if ns, pod, ok := strings.Cut(p.pod, "/"); ok {
+		p.namespace, p.pod = ns, pod
+	}
+	return p
+}
+
+func init() {
+	rootCmd.AddCommand(cpCmd)
+}
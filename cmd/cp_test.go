@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCopyPath(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want copyPath
+	}{
+		{"cpu.pprof", copyPath{path: "cpu.pprof"}},
+		{"./a:b", copyPath{path: "./a:b"}},
+		{"/tmp/a:b", copyPath{path: "/tmp/a:b"}},
+		{"aws-cli-pod-x7k2q:/tmp/cpu.pprof", copyPath{pod: "aws-cli-pod-x7k2q", path: "/tmp/cpu.pprof"}},
+		{"tools/aws-cli-pod:/root/.aws", copyPath{namespace: "tools", pod: "aws-cli-pod", path: "/root/.aws"}},
+		{"aws-cli-pod:", copyPath{pod: "aws-cli-pod"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseCopyPath(tt.arg))
+		})
+	}
+}
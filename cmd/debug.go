@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/cwxstat/go-pod-launch-run/pkg"
+	"github.com/spf13/cobra"
+)
+
+// debugCmd runs the commands in an ephemeral container of an existing pod
+var debugCmd = &cobra.Command{
+	Use:   "debug --target-pod pod [-- command...]",
+	Short: "Run the commands in an ephemeral container of an existing pod",
+	Long: `Adds an ephemeral container (aws-cli unless --image is given) to an existing
+pod in --namespace, so that the commands run inside the network namespace of
+the pod, and runs the commands in it. With --target-container the container
+also shares the process namespace of that container.
+
+Ephemeral containers cannot be removed: the debug container stays until its
+keepalive command exits.
+`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := launcherOptions()
+		if err != nil {
+			return err
+		}
+		cmdOpts, err := commandOptions(args)
+		if err != nil {
+			return err
+		}
+		_, err = pkg.NewLauncher(append(opts, cmdOpts...)...).Debug(cmd.Context(), pkg.DebugOptions{
+			TargetPod:       debugTargetPod,
+			TargetContainer: debugTargetContainer,
+		})
+		return err
+	},
+}
+
+var (
+	debugTargetPod       string
+	debugTargetContainer string
+)
+
+func init() {
+	rootCmd.AddCommand(debugCmd)
+
+	debugCmd.Flags().StringVar(&debugTargetPod, "target-pod", "", "Existing pod to add the debug container to")
+	debugCmd.Flags().StringVar(&debugTargetContainer, "target-container", "",
+		"Container of the target pod whose process namespace the debug container joins")
+	cobra.CheckErr(debugCmd.MarkFlagRequired("target-pod"))
+	addCommandFlags(debugCmd)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"github.com/cwxstat/go-pod-launch-run/pkg"
+	"github.com/spf13/cobra"
+)
+
+// execCmd runs the commands in an existing pod
+var execCmd = &cobra.Command{
+	Use:   "exec (--pod pod | --selector selector) [-- command...]",
+	Short: "Run the commands in an existing pod",
+	Long: `Runs the commands in a running pod of --namespace, selected by name or by
+label selector, without launching or deleting anything. With a selector the
+first running matching pod, by name, is used. The commands run in --container
+when given, otherwise in the pod's default container.
+
+Output, --format and the exit status are the same as for gopl itself.
+`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := launcherOptions()
+		if err != nil {
+			return err
+		}
+		cmdOpts, err := commandOptions(args)
+		if err != nil {
+			return err
+		}
+		execOpts := pkg.ExecOptions{Pod: execPod, Selector: execSelector}
+		if cmd.Flags().Changed("container") {
+			execOpts.Container = container
+		}
+		_, err = pkg.NewLauncher(append(opts, cmdOpts...)...).Exec(cmd.Context(), execOpts)
+		return err
+	},
+}
+
+var (
+	execPod      string
+	execSelector string
+)
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+
+	execCmd.Flags().StringVar(&execPod, "pod", "", "Name of the pod to run the commands in")
+	execCmd.Flags().StringVarP(&execSelector, "selector", "l", "", "Label selector of the pod to run the commands in")
+	execCmd.MarkFlagsMutuallyExclusive("pod", "selector")
+	addCommandFlags(execCmd)
+}
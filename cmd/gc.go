@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/cwxstat/go-pod-launch-run/pkg"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// gcCmd deletes the pods left behind by earlier runs
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete pods left behind by earlier runs",
+	Long: `Lists the pods carrying the app.kubernetes.io/managed-by=gopl label in
+--namespace, or in every namespace with --all-namespaces, and deletes those
+matching --older-than, --owner and --phase. Pods are left behind when gopl
+crashes, in vscode mode, or with --cleanup never. The Jobs of --backend job
+are deleted together with their pods, as are Jobs whose pod is already gone.
+
+Use --dry-run to only list them.
+`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var phases []corev1.PodPhase
+		for _, s := range gcPhases {
+			phase, err := pkg.ParsePodPhase(s)
+			if err != nil {
+				return err
+			}
+			phases = append(phases, phase)
+		}
+		opts, err := launcherOptions()
+		if err != nil {
+			return err
+		}
+		_, err = pkg.NewLauncher(opts...).GC(cmd.Context(), pkg.GCOptions{
+			AllNamespaces: gcAllNamespaces,
+			OlderThan:     gcOlderThan,
+			User:          gcOwner,
+			Phases:        phases,
+			DryRun:        gcDryRun,
+			Out:           os.Stdout,
+		})
+		return err
+	},
+}
+
+var (
+	gcAllNamespaces bool
+	gcOlderThan     time.Duration
+	gcOwner         string
+	gcPhases        []string
+	gcDryRun        bool
+)
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+
+	gcCmd.Flags().BoolVarP(&gcAllNamespaces, "all-namespaces", "A", false, "Look for pods in every namespace")
+	gcCmd.Flags().DurationVar(&gcOlderThan, "older-than", time.Hour, "Only delete pods created at least this long ago")
+	gcCmd.Flags().StringVar(&gcOwner, "owner", "", "Only delete pods launched by this user")
+	gcCmd.Flags().StringSliceVar(&gcPhases, "phase", nil,
+		"Only delete pods in these phases: Pending, Running, Succeeded, Failed or Unknown")
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "List the pods without deleting them")
+}
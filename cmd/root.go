@@ -4,8 +4,16 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/cwxstat/go-pod-launch-run/pkg"
+	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -14,37 +22,238 @@ import (
 var rootCmd = &cobra.Command{
 	Use:   "gopl",
 	Short: "Pod launch and run command",
-	Long: `Command launches a aws-cli pod and runs a command in it.
+	Long: `Command launches a pod (aws-cli unless --image is given) and runs a command in it.
 
+Exit status is 0 on success. When a single command ran and failed, gopl exits
+with that command's exit status. Otherwise: 1 when commands failed, 80 when the
+pod could not be launched, 81 when it did not start, 82 when a command could
+not be executed, 83 when the pod could not be deleted and 130 when interrupted.
 `,
-	// Uncomment the following line if your bare application
-	// has an action associated with it:
-	Run: func(cmd *cobra.Command, args []string) {
-		err := pkg.Run(podName, namespace, container, serviceaccount, vscodeDebug, args, outputFile)
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policy, err := pkg.ParseCleanupPolicy(runCleanup)
 		if err != nil {
-			return
+			return err
 		}
+		runBackend, err := pkg.ParseBackend(backend)
+		if err != nil {
+			return err
+		}
+		opts, err := launcherOptions()
+		if err != nil {
+			return err
+		}
+		cmdOpts, err := commandOptions(args)
+		if err != nil {
+			return err
+		}
+		launcher := pkg.NewLauncher(append(append(opts, cmdOpts...),
+			pkg.WithBackend(runBackend),
+			pkg.WithCleanupPolicy(policy),
+			pkg.WithVscodeDebug(vscodeDebug),
+		)...)
+		if perNode {
+			_, err = launcher.RunNodes(cmd.Context(), nodeSelector, parallel)
+			return err
+		}
+		if allContexts || len(contexts) > 0 {
+			names := contexts
+			if allContexts {
+				names, err = pkg.ListContexts(pkg.KubeConfig{Kubeconfig: kubeconfig})
+				if err != nil {
+					return err
+				}
+			}
+			_, err = launcher.RunContexts(cmd.Context(), names, parallel)
+			return err
+		}
+		_, err = launcher.Run(cmd.Context())
+		return err
 	},
 }
 
+// commandOptions returns the Launcher options of the subcommands running a
+// batch of commands, set by the flags added by addCommandFlags.
+func commandOptions(commands []string) ([]pkg.Option, error) {
+	if commandsFile != "" {
+		if len(commands) > 0 {
+			return nil, errors.New("give the commands either as arguments or with --commands-file")
+		}
+		var err error
+		commands, err = pkg.LoadCommands(commandsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var opts []pkg.Option
+	if scriptFile != "" {
+		if len(commands) > 0 {
+			return nil, errors.New("give either commands or --script")
+		}
+		script, err := pkg.LoadScript(scriptFile, strings.Fields(interpreter)...)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, pkg.WithScript(script))
+	}
+	format, err := pkg.ParseOutputFormat(outputFormat)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := pkg.ParseErrorPolicy(onError)
+	if err != nil {
+		return nil, err
+	}
+	if maxFailures > 0 {
+		policy = pkg.StopAfter(maxFailures)
+	}
+	var streamStdout, streamStderr io.Writer
+	if stream {
+		streamStdout, streamStderr = os.Stdout, os.Stderr
+	}
+	return append(opts,
+		pkg.WithCommands(commands...),
+		pkg.WithErrorPolicy(policy),
+		pkg.WithOutputFile(outputFile),
+		pkg.WithOutputFormat(format),
+		pkg.WithOutput(streamStdout, streamStderr),
+		pkg.WithOutputPrefix(prefix),
+	), nil
+}
+
+// addCommandFlags adds the flags of the subcommands running a batch of
+// commands.
+func addCommandFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&commandsFile, "commands-file", "",
+		"File with one command per line, # comments and \\ line continuations; - reads stdin")
+	cmd.Flags().StringVar(&scriptFile, "script", "",
+		"Local script to upload and run in the pod as a single command, instead of commands")
+	cmd.Flags().StringVar(&interpreter, "interpreter", "",
+		"Interpreter running --script, such as bash or \"python3 -u\" (default: the script's #! line)")
+	cmd.MarkFlagsMutuallyExclusive("commands-file", "script")
+	cmd.Flags().StringVar(&outputFormat, "format", "text", "Output file format: text, json or yaml")
+	cmd.Flags().BoolVar(&stream, "stream", true, "Stream command output to the terminal while it runs")
+	cmd.Flags().BoolVar(&prefix, "prefix", false, "Prefix streamed output lines with the command")
+	cmd.Flags().StringVar(&onError, "on-error", "fail-fast", "What to do when a command fails: fail-fast or continue")
+	cmd.Flags().IntVar(&maxFailures, "max-failures", 0, "Stop after this many failed commands (overrides --on-error)")
+}
+
+// launcherOptions returns the Launcher options shared by all subcommands.
+func launcherOptions() ([]pkg.Option, error) {
+	pullPolicy, err := pkg.ParseImagePullPolicy(imagePullPolicy)
+	if err != nil {
+		return nil, err
+	}
+	opts := []pkg.Option{}
+	if onConflict != "" {
+		conflict, err := pkg.ParseConflictPolicy(onConflict)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, pkg.WithConflictPolicy(conflict))
+	}
+	if podTemplate != "" {
+		template, err := pkg.LoadPodTemplate(podTemplate)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, pkg.WithPodTemplate(template))
+	}
+	opts = append(opts,
+		pkg.WithKubeConfig(pkg.KubeConfig{
+			Kubeconfig:        kubeconfig,
+			Context:           kubeContext,
+			Cluster:           kubeCluster,
+			User:              kubeUser,
+			Impersonate:       impersonate,
+			ImpersonateGroups: impersonateGroups,
+		}),
+		pkg.WithPodName(podName),
+		pkg.WithNamespace(namespace),
+		pkg.WithContainerName(container),
+		pkg.WithServiceAccount(serviceaccount),
+		pkg.WithImage(image),
+		pkg.WithImagePullPolicy(pullPolicy),
+		pkg.WithPortForward(forwards...),
+		pkg.WithStartupTimeout(startupTimeout),
+		pkg.WithTTL(ttl),
+		pkg.WithLease(lease),
+		pkg.WithLog(os.Stdout),
+	)
+	if ttl < 0 || lease < 0 {
+		return nil, errors.New("--ttl and --lease must not be negative")
+	}
+	if lease > 0 && lease < pkg.MinLease {
+		return nil, fmt.Errorf("--lease must be at least %s", pkg.MinLease)
+	}
+	if keepaliveCommand != "" {
+		opts = append(opts, pkg.WithKeepaliveCommand(strings.Fields(keepaliveCommand)...))
+	}
+	return opts, nil
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// SIGINT and SIGTERM cancel the command context; the launched pod is still
+// deleted before exiting. A second signal exits immediately.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
-		os.Exit(1)
+		os.Exit(pkg.ExitCode(err))
 	}
 }
 
+var kubeconfig string
+var kubeContext string
+var kubeCluster string
+var kubeUser string
+var impersonate string
+var impersonateGroups []string
+
 var podName string
 var namespace string
 var container string
 var serviceaccount string
+var onConflict string
+var podTemplate string
+var image string
+var imagePullPolicy string
+var keepaliveCommand string
+
+var forwards []string
+var startupTimeout time.Duration
+var ttl time.Duration
+var lease time.Duration
 
 var outputFile string
+var outputFormat string
+var stream bool
+var prefix bool
+
+var contexts []string
+var allContexts bool
+var parallel int
+var perNode bool
+var nodeSelector string
+
+var commandsFile string
+var scriptFile string
+var interpreter string
+var backend string
+var onError string
+var maxFailures int
 
 var vscodeDebug = false
 
+var runCleanup string
+
 func init() {
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
@@ -52,14 +261,53 @@ func init() {
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.mylaunch.yaml)")
 
-	rootCmd.PersistentFlags().StringVar(&podName, "podName", "aws-cli-pod", "Pod name")
+	rootCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file (default: $KUBECONFIG or ~/.kube/config)")
+	rootCmd.PersistentFlags().StringVar(&kubeContext, "context", "", "Kubeconfig context to use")
+	rootCmd.PersistentFlags().StringVar(&kubeCluster, "cluster", "", "Kubeconfig cluster to use")
+	rootCmd.PersistentFlags().StringVar(&kubeUser, "user", "", "Kubeconfig user to use")
+	rootCmd.PersistentFlags().StringVar(&impersonate, "as", "", "User to impersonate")
+	rootCmd.PersistentFlags().StringArrayVar(&impersonateGroups, "as-group", nil, "Group to impersonate (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&podName, "podName", "", "Pod name (default: a unique name starting with aws-cli-pod-)")
 	rootCmd.PersistentFlags().StringVar(&namespace, "namespace", "default", "Namespace")
 	rootCmd.PersistentFlags().StringVar(&container, "container", "aws-cli", "Container name")
-	rootCmd.PersistentFlags().StringVar(&serviceaccount, "serviceaccount", "default", "Service account name")
+	rootCmd.PersistentFlags().StringVar(&serviceaccount, "serviceaccount", "",
+		"Service account name (default: from --pod-template, or the namespace default)")
+	rootCmd.PersistentFlags().StringVar(&onConflict, "on-conflict", "",
+		"When the pod already exists: ask, reuse, replace, fail or generate a unique name (default: ask, reuse for shell)")
+	rootCmd.PersistentFlags().StringVar(&podTemplate, "pod-template", "",
+		"Pod or PodTemplate YAML file the pod is built from; the --container container is added if missing")
+	rootCmd.PersistentFlags().StringVar(&image, "image", "",
+		"Container image (default: from --pod-template, or amazon/aws-cli:latest)")
+	rootCmd.PersistentFlags().StringVar(&imagePullPolicy, "image-pull-policy", "",
+		"Image pull policy: Always, IfNotPresent or Never (default: cluster default)")
+	rootCmd.PersistentFlags().StringVar(&keepaliveCommand, "keepalive-command", "",
+		"Container command keeping the pod alive, split on whitespace (default: from --pod-template, or \"sleep 3600\", \"sleep <ttl>\" with --ttl)")
+	rootCmd.PersistentFlags().DurationVar(&startupTimeout, "startup-timeout", 5*time.Minute,
+		"How long to wait for the pod to be running (0 waits forever)")
+	rootCmd.PersistentFlags().DurationVar(&ttl, "ttl", 0,
+		"Kill the pod after this long even if gopl never deletes it, via activeDeadlineSeconds (0 disables)")
+	rootCmd.PersistentFlags().DurationVar(&lease, "lease", 0,
+		"Put a lease of at least 3s on the pod, renewed while gopl runs; gc keeps pods with a live lease (0 disables)")
+	rootCmd.PersistentFlags().StringArrayVar(&forwards, "forward", nil,
+		"Forward a local port to the pod while it is in use, as local:remote (repeatable)")
 	rootCmd.PersistentFlags().StringVar(&outputFile, "output", "result.pod", "Output file")
+	addCommandFlags(rootCmd)
+	rootCmd.Flags().StringVar(&backend, "backend", string(pkg.BackendExec),
+		"How to run the commands: exec into a pod, or job to run them as a Job and read its logs (no pods/exec needed)")
+	rootCmd.Flags().StringSliceVar(&contexts, "contexts", nil,
+		"Run in each of these kubeconfig contexts concurrently and report per context")
+	rootCmd.Flags().BoolVar(&allContexts, "all-contexts", false, "Run in every kubeconfig context, like --contexts")
+	rootCmd.Flags().BoolVar(&perNode, "per-node", false,
+		"Run in one pod per node, pinned with nodeName, concurrently, and report per node")
+	rootCmd.Flags().StringVar(&nodeSelector, "node-selector", "", "Label selector of the nodes --per-node runs on (default: all nodes)")
+	rootCmd.Flags().IntVar(&parallel, "parallel", 4, "How many contexts or nodes run at once with --contexts or --per-node (0 for all)")
+	rootCmd.MarkFlagsMutuallyExclusive("context", "contexts", "all-contexts")
+	rootCmd.MarkFlagsMutuallyExclusive("per-node", "contexts", "all-contexts")
+	rootCmd.MarkFlagsMutuallyExclusive("forward", "per-node", "contexts", "all-contexts")
 	rootCmd.PersistentFlags().BoolVar(&vscodeDebug, "vscodeDebug", false, "Debug with vscode")
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
+	rootCmd.Flags().StringVar(&runCleanup, "cleanup", string(pkg.CleanupAlways), "When to delete the pod after the commands ran: always, on-success or never")
 
 	//rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
@@ -3,6 +3,7 @@
 package cmd
 
 import (
+	"context"
 	"github.com/cwxstat/go-pod-launch-run/pkg"
 	"testing"
 )
@@ -18,11 +19,15 @@ func TestExecute(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			pkg.Run("dev2", "default",
-				"aws-cli",
-				"default",
-				true, nil,
-				"resultTest.pod")
+			launcher := pkg.NewLauncher(
+				pkg.WithPodName("dev2"),
+				pkg.WithNamespace("default"),
+				pkg.WithContainerName("aws-cli"),
+				pkg.WithServiceAccount("default"),
+				pkg.WithVscodeDebug(true),
+				pkg.WithOutputFile("resultTest.pod"),
+			)
+			launcher.Run(context.Background())
 		})
 	}
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/cwxstat/go-pod-launch-run/pkg"
+	"github.com/spf13/cobra"
+)
+
+// shellCmd opens an interactive shell in the pod
+var shellCmd = &cobra.Command{
+	Use:   "shell [-- command...]",
+	Short: "Open an interactive shell in the pod",
+	Long: `Launches the pod, or reuses it when it already exists, and opens an
+interactive shell in it. bash is used when the image has it, sh otherwise;
+pass a command after -- to run something else.
+
+When the shell exits the pod is deleted according to --cleanup, unless it
+existed before.
+`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policy, err := pkg.ParseCleanupPolicy(shellCleanup)
+		if err != nil {
+			return err
+		}
+		opts, err := launcherOptions()
+		if err != nil {
+			return err
+		}
+		launcher := pkg.NewLauncher(append(opts,
+			pkg.WithCleanupPolicy(policy),
+		)...)
+		_, err = launcher.Shell(cmd.Context(), pkg.ShellOptions{
+			Command: args,
+			Stdin:   os.Stdin,
+			Stdout:  os.Stdout,
+			Stderr:  os.Stderr,
+		})
+		return err
+	},
+}
+
+var shellCleanup string
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+
+	shellCmd.Flags().StringVar(&shellCleanup, "cleanup", string(pkg.CleanupAlways),
+		"When to delete the pod after the shell exits: always, on-success or never")
+}
@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// LoadCommands reads a batch of commands for WithCommands from a file, or from
+// stdin when path is "-", see ParseCommands.
+func LoadCommands(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	commands, err := ParseCommands(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load commands from %s: %w", path, err)
+	}
+	return commands, nil
+}
+
+// ParseCommands reads one command per line. Blank lines and lines starting with
+// # are skipped, and a line ending with a backslash continues on the next
+// line, as in a shell script. At least one command is required.
+func ParseCommands(r io.Reader) ([]string, error) {
+	var commands []string
+	var current strings.Builder
+	continued := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if !continued {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			line = trimmed
+		}
+
+		if strings.HasSuffix(line, `\`) {
+			current.WriteString(strings.TrimSuffix(line, `\`))
+			continued = true
+			continue
+		}
+		current.WriteString(line)
+		commands = append(commands, strings.TrimSpace(current.String()))
+		current.Reset()
+		continued = false
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if continued {
+		return nil, fmt.Errorf("line %d: the last command ends with a line continuation", lineNumber)
+	}
+	if len(commands) == 0 {
+		return nil, errors.New("no commands")
+	}
+	return commands, nil
+}
@@ -0,0 +1,47 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommands(t *testing.T) {
+	commands, err := ParseCommands(strings.NewReader(`# Check the IRSA wiring
+aws sts get-caller-identity
+
+  # List the buckets
+aws s3 ls \
+    --region us-east-1 \
+    --debug
+env | grep AWS_ # inline comments are left to the shell
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"aws sts get-caller-identity",
+		"aws s3 ls     --region us-east-1     --debug",
+		"env | grep AWS_ # inline comments are left to the shell",
+	}, commands)
+
+	_, err = ParseCommands(strings.NewReader("# nothing to do\n\n"))
+	assert.Error(t, err)
+
+	_, err = ParseCommands(strings.NewReader("aws s3 ls \\\n"))
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestLoadCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runbook.sh")
+	require.NoError(t, os.WriteFile(path, []byte("echo one\r\necho two\r\n"), 0o600))
+
+	commands, err := LoadCommands(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo one", "echo two"}, commands)
+
+	_, err = LoadCommands(filepath.Join(t.TempDir(), "missing.sh"))
+	assert.Error(t, err)
+}
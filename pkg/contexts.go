@@ -0,0 +1,47 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// ListContexts returns the names of the contexts in the kubeconfig selected by
+// k, sorted.
+func ListContexts(k KubeConfig) ([]string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = k.Kubeconfig
+	config, err := rules.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Kubernetes configuration: %w", err)
+	}
+	var names []string
+	for name := range config.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// RunContexts runs the whole lifecycle of Run against each kubeconfig context
+// concurrently, at most parallelism at a time; zero runs them all at once.
+//
+// Command output is not streamed, since the runs would interleave. With the
+// text format each context writes its output files with the context name
+// appended, for example result.pod.dev; otherwise the MultiResult is written
+// to the output file. A summary table is written to the log writer.
+func (l *Launcher) RunContexts(ctx context.Context, contexts []string, parallelism int) (*MultiResult, error) {
+	if _, err := ParseOutputFormat(string(l.outputFormat)); err != nil {
+		return nil, err
+	}
+	multi := &MultiResult{
+		Contexts: fanOut(ctx, contexts, parallelism, func(name string) *Launcher {
+			c := l.forTarget(name)
+			c.kubeConfig.Context = name
+			return c
+		}),
+	}
+	return multi, l.report(multi)
+}
@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+func TestLauncherRunContexts(t *testing.T) {
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "result.pod")
+
+	l := NewLauncher(
+		WithClientset(newRunningPodClientset()),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(&mockSPDYExecutorFactory{executor: &mockExecutor{stdout: "hello"}}),
+		WithNamespace("test-namespace"),
+		WithCommands("echo hello"),
+		WithOutputFile(outputFile),
+	)
+	multi, err := l.RunContexts(context.Background(), []string{"dev", "arn:aws:eks:us-east-1:1:cluster/prod"}, 1)
+	require.NoError(t, err)
+	require.Len(t, multi.Contexts, 2)
+	for name, cr := range multi.Contexts {
+		assert.Equal(t, ExitOK, cr.ExitCode, name)
+		assert.Empty(t, cr.Error, name)
+		if assert.Len(t, cr.Result.Commands, 1, name) {
+			assert.Equal(t, "hello", cr.Result.Commands[0].Stdout)
+		}
+	}
+
+	out, err := os.ReadFile(outputFile + ".dev")
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(out))
+	assert.FileExists(t, outputFile+".arn_aws_eks_us-east-1_1_cluster_prod")
+}
+
+func TestLauncherForTarget(t *testing.T) {
+	l := NewLauncher(
+		WithOutput(io.Discard, io.Discard),
+		WithOutputFile("result.pod"),
+		WithPortForward("8080:8080"),
+	)
+	c := l.forTarget("dev")
+	assert.Nil(t, c.stdout)
+	assert.Equal(t, "result.pod.dev", c.outputFile)
+	assert.Empty(t, c.forwards, "concurrent targets cannot share local ports")
+	assert.Equal(t, []string{"8080:8080"}, l.forwards)
+}
+
+func TestLauncherRunContextsJSON(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "result.json")
+
+	l := NewLauncher(
+		WithClientset(newRunningPodClientset()),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(&mockSPDYExecutorFactory{executor: &mockExecutor{stdout: "hello"}}),
+		WithNamespace("test-namespace"),
+		WithCommands("echo hello"),
+		WithOutputFile(outputFile),
+		WithOutputFormat(FormatJSON),
+	)
+	_, err := l.RunContexts(context.Background(), []string{"dev", "prod"}, 0)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	var multi MultiResult
+	require.NoError(t, json.Unmarshal(data, &multi))
+	assert.Contains(t, multi.Contexts, "dev")
+	assert.Contains(t, multi.Contexts, "prod")
+}
+
+func TestMultiResultErr(t *testing.T) {
+	multi := &MultiResult{Contexts: map[string]*TargetResult{
+		"dev":     {ExitCode: ExitOK},
+		"staging": {ExitCode: ExitLaunchFailed},
+		"prod":    {ExitCode: ExitLaunchFailed},
+	}}
+	err := multi.err()
+	var targetsErr *TargetsFailedError
+	require.ErrorAs(t, err, &targetsErr)
+	assert.Equal(t, []string{"prod", "staging"}, targetsErr.Failed)
+	assert.Equal(t, ExitLaunchFailed, ExitCode(err))
+
+	multi.Contexts["prod"].ExitCode = ExitStartupFailed
+	assert.Equal(t, ExitCommandFailed, ExitCode(multi.err()))
+
+	multi.Contexts["prod"].ExitCode = ExitOK
+	multi.Contexts["staging"].ExitCode = ExitOK
+	assert.NoError(t, multi.err())
+}
+
+func TestWriteMultiResultText(t *testing.T) {
+	var out bytes.Buffer
+	multi := &MultiResult{Contexts: map[string]*TargetResult{
+		"prod": {ExitCode: ExitLaunchFailed, Error: "forbidden"},
+		"dev":  {Result: &Result{PodName: "aws-cli-pod-abcde", Commands: make([]CommandResult, 2)}},
+	}}
+	require.NoError(t, WriteMultiResult(&out, multi, FormatText))
+	assert.Equal(t, `CONTEXT  POD                COMMANDS  EXIT  ERROR
+dev      aws-cli-pod-abcde  2         0     
+prod                        0         80    forbidden
+`, out.String())
+}
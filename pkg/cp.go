@@ -0,0 +1,283 @@
+package pkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// CopyToPod copies the local file or directory src, recursively, to the path
+// dst in a container of the pod, like kubectl cp: dst names the copy, it is not
+// the directory the copy goes into. An empty container selects the pod's
+// default container. The container needs tar.
+func (l *Launcher) CopyToPod(ctx context.Context, pod, container, src, dst string) error {
+	if err := l.init(); err != nil {
+		return &StageError{Stage: StageLaunch, Err: err}
+	}
+	container, err := l.podContainer(ctx, pod, container)
+	if err != nil {
+		return &StageError{Stage: StageLaunch, Err: err}
+	}
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+
+	dst = path.Clean(dst)
+	command := []string{"tar", "-xmf", "-"}
+	if dir := path.Dir(dst); dir != "." {
+		command = append(command, "-C", dir)
+	}
+
+	reader, writer := io.Pipe()
+	writeErr := make(chan error, 1)
+	go func() {
+		err := writeTar(writer, src, path.Base(dst), l.log)
+		writer.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	var stderr bytes.Buffer
+	err = l.streamCopy(ctx, pod, container, command, remotecommand.StreamOptions{
+		Stdin:  reader,
+		Stdout: io.Discard,
+		Stderr: &stderr,
+	})
+	// Unblock writeTar when the stream ended before reading the whole archive.
+	reader.Close()
+	// The stream only sees the end of its stdin, so a local read error would
+	// otherwise pass for a complete archive.
+	if tarErr := <-writeErr; tarErr != nil {
+		switch {
+		case !errors.Is(tarErr, io.ErrClosedPipe):
+			return &StageError{Stage: StageExec, Err: l.interrupted(ctx,
+				fmt.Errorf("failed to copy %s to %s:%s, the copy may be incomplete: %w", src, pod, dst, tarErr))}
+		case err == nil:
+			err = errors.New("the container stopped reading the archive")
+		}
+	}
+	if err != nil {
+		return &StageError{Stage: StageExec, Err: l.interrupted(ctx, copyError(src, pod+":"+dst, err, &stderr))}
+	}
+	l.logf("Copied %s to %s:%s.\n", src, pod, dst)
+	return nil
+}
+
+// CopyFromPod copies the file or directory src, recursively, from a container
+// of the pod to the local path dst, like kubectl cp. An empty container selects
+// the pod's default container. The container needs tar.
+//
+// Entries of the archive that would be written outside dst are refused, and
+// links are skipped.
+func (l *Launcher) CopyFromPod(ctx context.Context, pod, container, src, dst string) error {
+	if err := l.init(); err != nil {
+		return &StageError{Stage: StageLaunch, Err: err}
+	}
+	container, err := l.podContainer(ctx, pod, container)
+	if err != nil {
+		return &StageError{Stage: StageLaunch, Err: err}
+	}
+
+	src = path.Clean(src)
+	command := []string{"tar", "-cf", "-", "-C", path.Dir(src), path.Base(src)}
+
+	reader, writer := io.Pipe()
+	var stderr bytes.Buffer
+	streamDone := make(chan error, 1)
+	go func() {
+		err := l.streamCopy(ctx, pod, container, command, remotecommand.StreamOptions{
+			Stdout: writer,
+			Stderr: &stderr,
+		})
+		writer.CloseWithError(err)
+		streamDone <- err
+	}()
+
+	err = readTar(reader, path.Base(src), dst, l.log)
+	if err == nil {
+		// tar pads the archive past its end marker.
+		_, err = io.Copy(io.Discard, reader)
+	}
+	// Unblock the stream when readTar stopped early.
+	reader.CloseWithError(errors.New("copy aborted"))
+	streamErr := <-streamDone
+	switch {
+	case streamErr != nil && (err == nil || errors.Is(err, streamErr)):
+		return &StageError{Stage: StageExec, Err: l.interrupted(ctx, copyError(pod+":"+src, dst, streamErr, &stderr))}
+	case err != nil:
+		return &StageError{Stage: StageExec,
+			Err: l.interrupted(ctx, fmt.Errorf("failed to copy %s:%s to %s: %w", pod, src, dst, err))}
+	}
+	l.logf("Copied %s:%s to %s.\n", pod, src, dst)
+	return nil
+}
+
+// podContainer returns container, or the default container of the pod when it
+// is empty.
+func (l *Launcher) podContainer(ctx context.Context, podName, container string) (string, error) {
+	if container != "" {
+		return container, nil
+	}
+	pod, err := l.clientset.CoreV1().Pods(l.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get pod %s in namespace %s: %w", podName, l.namespace, err)
+	}
+	return defaultContainer(pod), nil
+}
+
+func (l *Launcher) streamCopy(ctx context.Context, pod, container string, command []string,
+	options remotecommand.StreamOptions) error {
+	execURL := execRequestURL(l.clientset.CoreV1(), l.namespace, pod, &corev1.PodExecOptions{
+		Container: container,
+		Command:   command,
+		Stdin:     options.Stdin != nil,
+		Stdout:    true,
+		Stderr:    true,
+	})
+	executor, err := l.executorFactory.NewSPDYExecutor(l.restConfig, "POST", execURL)
+	if err != nil {
+		return err
+	}
+	return executor.StreamWithContext(ctx, options)
+}
+
+func copyError(src, dst string, err error, stderr *bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("failed to copy %s to %s: %w: %s", src, dst, err, msg)
+	}
+	return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+}
+
+// interrupted reports an interrupt of ctx as the cause of the copy error err,
+// as Run does, since the torn down stream rarely says why it ended.
+func (l *Launcher) interrupted(ctx context.Context, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+	l.logf("Interrupted.\n")
+	if errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ctx.Err(), err)
+}
+
+// writeTar writes the local file or directory src to w as a tar archive whose
+// entries are named after name. Only directories and regular files are
+// copied; the others are reported to log.
+func writeTar(w io.Writer, src, name string, log io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			logf(log, "Skipping %s: not a regular file or directory.\n", file)
+			return nil
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(name, filepath.ToSlash(rel))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// readTar extracts the tar archive read from r, whose entries are named after
+// name, to dst. Entries outside name, or that would be written outside dst,
+// are refused. Other entries, such as links, are skipped and reported to log.
+func readTar(r io.Reader, name, dst string, log io.Writer) error {
+	dst = filepath.Clean(dst)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		entry := path.Clean(header.Name)
+		var rel string
+		switch {
+		case entry == name:
+		case strings.HasPrefix(entry, name+"/"):
+			rel = strings.TrimPrefix(entry, name+"/")
+		default:
+			return fmt.Errorf("refusing to extract %q: not under %q", header.Name, name)
+		}
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		if !withinDir(dst, target) {
+			return fmt.Errorf("refusing to extract %q outside %s", header.Name, dst)
+		}
+
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode|0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			if err := writeFile(target, tr, mode); err != nil {
+				return err
+			}
+		default:
+			logf(log, "Skipping %s: not a regular file or directory.\n", header.Name)
+		}
+	}
+}
+
+// withinDir reports whether target is dir or inside it.
+func withinDir(dir, target string) bool {
+	rel, err := filepath.Rel(dir, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,175 @@
+package pkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+func newCopyLauncher(factory SPDYExecutorFactory) *Launcher {
+	return NewLauncher(
+		WithClientset(newRunningPodClientset()),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(factory),
+		WithNamespace("test-namespace"),
+	)
+}
+
+func TestCopyRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "nested", "empty"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "top.txt"), []byte("top"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "nested", "run.sh"), []byte("#!/bin/sh\n"), 0o755))
+
+	upload := &recordingExecutor{}
+	require.NoError(t, newCopyLauncher(upload).CopyToPod(ctx, "test-pod", "aws-cli", src, "/tmp/copy"))
+	require.Len(t, upload.urls, 1)
+	assert.Equal(t, []string{"tar", "-xmf", "-", "-C", "/tmp"}, upload.urls[0].Query()["command"])
+	assert.Equal(t, "true", upload.urls[0].Query().Get("stdin"))
+
+	download := &recordingExecutor{exec: mockExecutor{stdout: upload.stdin.String()}}
+	dst := filepath.Join(t.TempDir(), "restored")
+	require.NoError(t, newCopyLauncher(download).CopyFromPod(ctx, "test-pod", "aws-cli", "/tmp/copy", dst))
+	assert.Equal(t, []string{"tar", "-cf", "-", "-C", "/tmp", "copy"}, download.urls[0].Query()["command"])
+
+	data, err := os.ReadFile(filepath.Join(dst, "top.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "top", string(data))
+	info, err := os.Stat(filepath.Join(dst, "nested", "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm(), "modes are kept")
+	assert.DirExists(t, filepath.Join(dst, "nested", "empty"))
+}
+
+func TestCopyFromPodSingleFile(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "cpu.pprof", Mode: 0o600, Size: 4, Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte("prof"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	// tar pads its archives well past the end marker.
+	archive.Write(make([]byte, 8192))
+
+	dst := filepath.Join(t.TempDir(), "local.pprof")
+	executor := &recordingExecutor{exec: mockExecutor{stdout: archive.String()}}
+	require.NoError(t, newCopyLauncher(executor).CopyFromPod(context.Background(), "test-pod", "aws-cli", "/tmp/cpu.pprof", dst))
+	data, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "prof", string(data))
+}
+
+func TestCopyFromPodRefusesTraversal(t *testing.T) {
+	for _, name := range []string{"data/../../evil", "/etc/evil", "other/evil"} {
+		t.Run(name, func(t *testing.T) {
+			var archive bytes.Buffer
+			tw := tar.NewWriter(&archive)
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: "data/", Mode: 0o755, Typeflag: tar.TypeDir}))
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: 4, Typeflag: tar.TypeReg}))
+			_, err := tw.Write([]byte("evil"))
+			require.NoError(t, err)
+			require.NoError(t, tw.Close())
+
+			root := t.TempDir()
+			executor := &recordingExecutor{exec: mockExecutor{stdout: archive.String()}}
+			err = newCopyLauncher(executor).CopyFromPod(context.Background(), "test-pod", "aws-cli", "/tmp/data",
+				filepath.Join(root, "out", "data"))
+			assert.ErrorContains(t, err, "refusing to extract")
+
+			var written []string
+			filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					written = append(written, path)
+				}
+				return nil
+			})
+			assert.Empty(t, written)
+		})
+	}
+}
+
+func TestCopyFromPodSkipsLinks(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "data/", Mode: 0o755, Typeflag: tar.TypeDir}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "data/passwd", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}))
+	require.NoError(t, tw.Close())
+
+	dst := filepath.Join(t.TempDir(), "data")
+	executor := &recordingExecutor{exec: mockExecutor{stdout: archive.String()}}
+	require.NoError(t, newCopyLauncher(executor).CopyFromPod(context.Background(), "test-pod", "aws-cli", "/tmp/data", dst))
+	_, err := os.Lstat(filepath.Join(dst, "passwd"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCopyFromPodStreamError(t *testing.T) {
+	executor := &recordingExecutor{exec: mockExecutor{
+		stderr: "tar: /tmp/missing: No such file or directory\n",
+		err:    assert.AnError,
+	}}
+	err := newCopyLauncher(executor).CopyFromPod(context.Background(), "test-pod", "aws-cli", "/tmp/missing", t.TempDir())
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "No such file or directory"), err.Error())
+	assert.Equal(t, ExitExecFailed, ExitCode(err))
+}
+
+func TestCopyInterrupted(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "top.txt")
+	require.NoError(t, os.WriteFile(src, []byte("top"), 0o644))
+
+	copies := map[string]func(l *Launcher, ctx context.Context) error{
+		"to": func(l *Launcher, ctx context.Context) error {
+			return l.CopyToPod(ctx, "test-pod", "aws-cli", src, "/tmp/top.txt")
+		},
+		"from": func(l *Launcher, ctx context.Context) error {
+			return l.CopyFromPod(ctx, "test-pod", "aws-cli", "/tmp/top.txt", t.TempDir())
+		},
+	}
+	for name, run := range copies {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			executor := &cancelExecutor{cancel: cancel, err: errors.New("connection reset by peer")}
+			err := run(newCopyLauncher(&mockSPDYExecutorFactory{executor: executor}), ctx)
+			assert.ErrorIs(t, err, context.Canceled)
+			assert.Equal(t, ExitInterrupted, ExitCode(err))
+		})
+	}
+}
+
+func TestCopyToPodReadError(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read any directory")
+	}
+	src := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "private"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "top.txt"), []byte("top"), 0o644))
+	require.NoError(t, os.Chmod(filepath.Join(src, "private"), 0))
+	defer os.Chmod(filepath.Join(src, "private"), 0o755)
+
+	// The executor ignores errors reading stdin, like remotecommand.
+	executor := &recordingExecutor{}
+	err := newCopyLauncher(executor).CopyToPod(context.Background(), "test-pod", "aws-cli", src, "/tmp/data")
+	assert.ErrorIs(t, err, os.ErrPermission)
+	assert.ErrorContains(t, err, "may be incomplete")
+}
+
+func TestCopyToPodStdinNotRead(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "top.txt")
+	require.NoError(t, os.WriteFile(src, []byte("top"), 0o644))
+
+	factory := &mockSPDYExecutorFactory{executor: &mockExecutor{}}
+	err := newCopyLauncher(factory).CopyToPod(context.Background(), "test-pod", "aws-cli", src, "/tmp/top.txt")
+	assert.ErrorContains(t, err, "stopped reading the archive")
+	assert.Equal(t, ExitExecFailed, ExitCode(err))
+}
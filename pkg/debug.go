@@ -0,0 +1,153 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	v1Inter "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// DebugOptions selects the pod Launcher.Debug attaches to.
+type DebugOptions struct {
+	// TargetPod is the name of the existing pod, in the launcher's namespace.
+	TargetPod string
+	// TargetContainer is the container of TargetPod whose process namespace
+	// the debug container joins. Empty shares only the network namespace.
+	TargetContainer string
+}
+
+// Debug adds an ephemeral container to an existing pod, so that the commands
+// run inside its network namespace, waits for the container to run and
+// executes the commands in it. The container is named after the launcher's
+// container name with a random suffix, and uses its image and keepalive
+// command.
+//
+// Ephemeral containers cannot be removed: the debug container stays until
+// its keepalive command exits, and the cleanup policy does not apply.
+func (l *Launcher) Debug(ctx context.Context, opts DebugOptions) (*Result, error) {
+	result := l.newResult()
+	result.PodName = opts.TargetPod
+	if _, err := ParseOutputFormat(string(l.outputFormat)); err != nil {
+		return result, err
+	}
+	if err := l.init(); err != nil {
+		return result, &StageError{Stage: StageLaunch, Err: err}
+	}
+	coreV1 := l.clientset.CoreV1()
+
+	container, err := l.addDebugContainer(ctx, coreV1, opts)
+	if err != nil {
+		return result, &StageError{Stage: StageLaunch, Err: err}
+	}
+	result.Container, result.Image = container.Name, container.Image
+	l.logf("Debug container %s added to pod %s.\n", container.Name, opts.TargetPod)
+
+	if err := waitForEphemeralContainer(ctx, coreV1, l.namespace, opts.TargetPod, container.Name, l.startupTimeout); err != nil {
+		return result, &StageError{Stage: StageStartup, Err: err}
+	}
+	l.logf("Debug container is running.\n")
+	return result, l.runCommands(ctx, coreV1, result)
+}
+
+// addDebugContainer adds the ephemeral container to the target pod through
+// the ephemeralcontainers subresource.
+func (l *Launcher) addDebugContainer(ctx context.Context, coreV1 v1Inter.CoreV1Interface,
+	opts DebugOptions) (*corev1.EphemeralContainer, error) {
+	pod, err := coreV1.Pods(l.namespace).Get(ctx, opts.TargetPod, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s in namespace %s: %w", opts.TargetPod, l.namespace, err)
+	}
+
+	command := l.keepaliveCommand
+	if len(command) == 0 {
+		command = defaultKeepaliveCommand
+	}
+	image := l.image
+	if image == "" {
+		image = defaultImage
+	}
+	container := corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:            generateName(l.containerName+"-debug", validation.DNS1123LabelMaxLength),
+			Image:           image,
+			ImagePullPolicy: l.imagePullPolicy,
+			Command:         command,
+		},
+		TargetContainerName: opts.TargetContainer,
+	}
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, container)
+
+	_, err = coreV1.Pods(l.namespace).UpdateEphemeralContainers(ctx, opts.TargetPod, pod, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add a debug container to pod %s in namespace %s: %w", opts.TargetPod, l.namespace, err)
+	}
+	return &container, nil
+}
+
+// diagnoseEphemeralContainer reports whether the ephemeral container of the
+// pod is running and otherwise what keeps it from running, if anything is
+// known.
+func diagnoseEphemeralContainer(pod *corev1.Pod, name string) (bool, *PodStartError) {
+	for _, cs := range pod.Status.EphemeralContainerStatuses {
+		if cs.Name != name {
+			continue
+		}
+		newErr := func(failure StartFailure, reason, message string) *PodStartError {
+			return &PodStartError{PodName: pod.Name, Namespace: pod.Namespace,
+				Failure: failure, Reason: reason, Message: message}
+		}
+		switch {
+		case cs.State.Running != nil:
+			return true, nil
+		case cs.State.Terminated != nil:
+			t := cs.State.Terminated
+			return false, newErr(StartCrashed, t.Reason,
+				fmt.Sprintf("container %s exited with code %d", name, t.ExitCode))
+		case cs.State.Waiting != nil && imagePullReasons[cs.State.Waiting.Reason]:
+			return false, newErr(StartImagePull, cs.State.Waiting.Reason, cs.State.Waiting.Message)
+		case cs.State.Waiting != nil && crashReasons[cs.State.Waiting.Reason]:
+			return false, newErr(StartCrashed, cs.State.Waiting.Reason, cs.State.Waiting.Message)
+		}
+	}
+	return false, nil
+}
+
+// waitForEphemeralContainer watches the pod until its ephemeral container is
+// running. It fails as soon as the container cannot start, or once
+// startupTimeout expires. A zero startupTimeout waits until ctx is done.
+func waitForEphemeralContainer(ctx context.Context, coreV1 v1Inter.CoreV1Interface, namespace, podName,
+	containerName string, startupTimeout time.Duration) error {
+	var timeoutCh <-chan time.Time
+	if startupTimeout > 0 {
+		timer := time.NewTimer(startupTimeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	lastIssue := &PodStartError{PodName: podName, Namespace: namespace, Failure: StartTimeout}
+	check := func(pod *corev1.Pod) (bool, error) {
+		running, issue := diagnoseEphemeralContainer(pod, containerName)
+		switch {
+		case running:
+			return true, nil
+		case issue != nil:
+			return true, issue
+		}
+		if _, podIssue := diagnosePod(pod); podIssue != nil && podIssue.Failure == StartPodTerminated {
+			return true, podIssue
+		}
+		return false, nil
+	}
+
+	err := watchPods(ctx, coreV1.Pods(namespace), podNameOptions(podName), getPod(ctx, coreV1, namespace, podName),
+		&timeoutCh, check)
+	if err == errWaitTimeout {
+		lastIssue.TimedOut = true
+		return lastIssue
+	}
+	return err
+}
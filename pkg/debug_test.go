@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newDebugClientset returns a clientset with a running app pod whose ephemeral
+// containers are reported in the given state once added.
+func newDebugClientset(t *testing.T, state corev1.ContainerState) *customFakeClientset {
+	clientset := newRunningPodClientset()
+	_, err := clientset.CoreV1().Pods("test-namespace").Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "test-namespace"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app:1"}}},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	clientset.PrependReactor("update", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "ephemeralcontainers" {
+			return false, nil, nil
+		}
+		pod := action.(k8stesting.UpdateAction).GetObject().(*corev1.Pod)
+		for _, c := range pod.Spec.EphemeralContainers {
+			pod.Status.EphemeralContainerStatuses = append(pod.Status.EphemeralContainerStatuses,
+				corev1.ContainerStatus{Name: c.Name, State: state})
+		}
+		return false, nil, nil
+	})
+	return clientset
+}
+
+func TestLauncherDebug(t *testing.T) {
+	ctx := context.Background()
+	clientset := newDebugClientset(t, corev1.ContainerState{Running: &corev1.ContainerStateRunning{}})
+
+	l := NewLauncher(
+		WithClientset(clientset),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(&mockSPDYExecutorFactory{executor: &mockExecutor{stdout: "hello"}}),
+		WithNamespace("test-namespace"),
+		WithCommands("echo hello"),
+		WithOutputFile(""),
+		WithOutput(io.Discard, io.Discard),
+	)
+	result, err := l.Debug(ctx, DebugOptions{TargetPod: "app", TargetContainer: "app"})
+	require.NoError(t, err)
+	assert.Equal(t, "app", result.PodName)
+	assert.Regexp(t, `^aws-cli-debug-[a-z0-9]{5}$`, result.Container)
+	assert.Equal(t, defaultImage, result.Image)
+	assert.False(t, result.Deleted)
+	require.Len(t, result.Commands, 1)
+	assert.Equal(t, "hello", result.Commands[0].Stdout)
+
+	pod, err := clientset.CoreV1().Pods("test-namespace").Get(ctx, "app", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, pod.Spec.EphemeralContainers, 1)
+	debug := pod.Spec.EphemeralContainers[0]
+	assert.Equal(t, result.Container, debug.Name)
+	assert.Equal(t, "app", debug.TargetContainerName)
+	assert.Equal(t, defaultKeepaliveCommand, debug.Command)
+}
+
+func TestLauncherDebugImagePull(t *testing.T) {
+	clientset := newDebugClientset(t, corev1.ContainerState{
+		Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull", Message: "not found"},
+	})
+
+	l := NewLauncher(
+		WithClientset(clientset),
+		WithRestConfig(&rest.Config{}),
+		WithNamespace("test-namespace"),
+		WithImage("missing:latest"),
+		WithOutputFile(""),
+	)
+	_, err := l.Debug(context.Background(), DebugOptions{TargetPod: "app"})
+	var startErr *PodStartError
+	require.ErrorAs(t, err, &startErr)
+	assert.Equal(t, StartImagePull, startErr.Failure)
+	assert.Equal(t, ExitStartupFailed, ExitCode(err))
+
+	_, err = l.Debug(context.Background(), DebugOptions{TargetPod: "missing"})
+	assert.Equal(t, ExitLaunchFailed, ExitCode(err))
+}
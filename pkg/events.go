@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	v1Inter "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// PodEvent is a Kubernetes event about the launched pod, such as Scheduled,
+// Pulling, Pulled, Failed or BackOff.
+type PodEvent struct {
+	Type    string      `json:"type"`
+	Reason  string      `json:"reason"`
+	Message string      `json:"message"`
+	Count   int32       `json:"count,omitempty"`
+	Time    metav1.Time `json:"time"`
+}
+
+// eventWatcher collects the events of a pod and prints them as they arrive.
+type eventWatcher struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	events []PodEvent
+}
+
+// watchPodEvents starts watching the events whose involved object is the pod.
+// When uid is set, events of earlier pods with the same name are ignored.
+func watchPodEvents(ctx context.Context, clientsetCoreV1 v1Inter.CoreV1Interface, namespace, podName string,
+	uid types.UID, out io.Writer) (*eventWatcher, error) {
+	selector := []string{
+		"involvedObject.kind=Pod",
+		fmt.Sprintf("involvedObject.name=%s", podName),
+		fmt.Sprintf("involvedObject.namespace=%s", namespace),
+	}
+	if uid != "" {
+		selector = append(selector, fmt.Sprintf("involvedObject.uid=%s", uid))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	watcher, err := clientsetCoreV1.Events(namespace).Watch(ctx, metav1.ListOptions{
+		FieldSelector: strings.Join(selector, ","),
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	w := &eventWatcher{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		defer watcher.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-watcher.ResultChan():
+				if !ok {
+					return
+				}
+				if e.Type != watch.Added && e.Type != watch.Modified {
+					continue
+				}
+				event, ok := e.Object.(*corev1.Event)
+				if !ok || event.InvolvedObject.Name != podName || (uid != "" && event.InvolvedObject.UID != uid) {
+					continue
+				}
+				podEvent := newPodEvent(event)
+				w.events = append(w.events, podEvent)
+				fmt.Fprintf(out, "  %s %s: %s\n", podEvent.Type, podEvent.Reason, podEvent.Message)
+			}
+		}
+	}()
+	return w, nil
+}
+
+// stop ends the watch and returns the events seen so far.
+func (w *eventWatcher) stop() []PodEvent {
+	w.cancel()
+	<-w.done
+	return w.events
+}
+
+func newPodEvent(event *corev1.Event) PodEvent {
+	t := event.LastTimestamp
+	if t.IsZero() {
+		t = metav1.NewTime(event.EventTime.Time)
+	}
+	return PodEvent{
+		Type:    event.Type,
+		Reason:  event.Reason,
+		Message: event.Message,
+		Count:   event.Count,
+		Time:    t,
+	}
+}
@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchPodEvents(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	namespace := "test-namespace"
+
+	var out syncBuffer
+	w, err := watchPodEvents(context.Background(), clientset.CoreV1(), namespace, "test-pod", "uid-1", &out)
+	assert.NoError(t, err)
+
+	newEvent := func(name, podName, uid, reason string) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: namespace},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: podName, Namespace: namespace, UID: types.UID(uid)},
+			Type:           corev1.EventTypeNormal,
+			Reason:         reason,
+			Message:        reason + " message",
+			LastTimestamp:  metav1.Now(),
+		}
+	}
+	for _, e := range []*corev1.Event{
+		newEvent("e1", "test-pod", "uid-1", "Scheduled"),
+		newEvent("e2", "other-pod", "uid-2", "Scheduled"),
+		newEvent("e3", "test-pod", "uid-0", "Killing"),
+		newEvent("e4", "test-pod", "uid-1", "Pulling"),
+	} {
+		_, err := clientset.CoreV1().Events(namespace).Create(context.Background(), e, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "Pulling")
+	}, 2*time.Second, 10*time.Millisecond)
+
+	events := w.stop()
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "Scheduled", events[0].Reason)
+		assert.Equal(t, "Pulling", events[1].Reason)
+	}
+	assert.Contains(t, out.String(), "Normal Scheduled: Scheduled message")
+	assert.NotContains(t, out.String(), "other-pod")
+	assert.NotContains(t, out.String(), "Killing", "events of an earlier pod with the same name are ignored")
+}
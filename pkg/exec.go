@@ -0,0 +1,122 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1Inter "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// defaultContainerAnnotation names the default container of a pod, as used by
+// kubectl.
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// ExecOptions selects the existing pod Launcher.Exec runs the commands in.
+type ExecOptions struct {
+	// Pod is the name of the pod, in the launcher's namespace.
+	Pod string
+	// Selector is a label selector used instead of Pod. The first running
+	// matching pod, by name, is used.
+	Selector string
+	// Container is the container to run the commands in. Empty selects the
+	// pod's default container, or its first one.
+	Container string
+}
+
+// Exec runs the commands in a running pod without launching or deleting
+// anything. Output and errors are handled as by Run.
+func (l *Launcher) Exec(ctx context.Context, opts ExecOptions) (*Result, error) {
+	result := l.newResult()
+	result.PodName, result.Container = opts.Pod, opts.Container
+	if _, err := ParseOutputFormat(string(l.outputFormat)); err != nil {
+		return result, err
+	}
+	if err := l.init(); err != nil {
+		return result, &StageError{Stage: StageLaunch, Err: err}
+	}
+	coreV1 := l.clientset.CoreV1()
+
+	pod, err := l.findPod(ctx, coreV1, opts)
+	if err != nil {
+		return result, &StageError{Stage: StageLaunch, Err: err}
+	}
+	result.PodName = pod.Name
+	if result.Container == "" {
+		result.Container = defaultContainer(pod)
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name == result.Container {
+			result.Image = c.Image
+		}
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return result, &StageError{Stage: StageStartup,
+			Err: fmt.Errorf("pod %s in namespace %s is not running: %s", pod.Name, l.namespace, pod.Status.Phase)}
+	}
+	return result, l.runCommands(ctx, coreV1, result)
+}
+
+// findPod returns the pod selected by opts.
+func (l *Launcher) findPod(ctx context.Context, coreV1 v1Inter.CoreV1Interface, opts ExecOptions) (*corev1.Pod, error) {
+	switch {
+	case opts.Pod != "" && opts.Selector != "":
+		return nil, fmt.Errorf("select the pod either by name or by label selector")
+	case opts.Pod != "":
+		pod, err := coreV1.Pods(l.namespace).Get(ctx, opts.Pod, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pod %s in namespace %s: %w", opts.Pod, l.namespace, err)
+		}
+		return pod, nil
+	case opts.Selector == "":
+		return nil, fmt.Errorf("no pod selected")
+	}
+
+	pods, err := coreV1.Pods(l.namespace).List(ctx, metav1.ListOptions{LabelSelector: opts.Selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in namespace %s: %w", l.namespace, err)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == corev1.PodRunning && pods.Items[i].DeletionTimestamp == nil {
+			return &pods.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no running pod matches selector %q in namespace %s", opts.Selector, l.namespace)
+}
+
+// defaultContainer returns the container kubectl exec would use.
+func defaultContainer(pod *corev1.Pod) string {
+	if name := pod.Annotations[defaultContainerAnnotation]; name != "" {
+		return name
+	}
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Name
+	}
+	return ""
+}
+
+// runCommands executes the commands in the container of the pod recorded in
+// result, which already exists and runs, and writes the output file. An
+// interrupt is reported as the cause of the error, as by Run.
+func (l *Launcher) runCommands(ctx context.Context, coreV1 v1Inter.CoreV1Interface, result *Result) error {
+	err := l.execCommands(ctx, coreV1, result)
+	if ctx.Err() != nil {
+		l.logf("Interrupted.\n")
+		if err != nil && !errors.Is(err, ctx.Err()) {
+			err = fmt.Errorf("%w: %v", ctx.Err(), err)
+		}
+	}
+	if len(result.Commands) > 0 {
+		if writeErr := l.writeOutputFile(result); writeErr != nil && err == nil {
+			return writeErr
+		}
+	}
+	if err != nil {
+		return &StageError{Stage: StageExec, Err: err}
+	}
+	return nil
+}
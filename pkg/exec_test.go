@@ -0,0 +1,104 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func appPod(name string, phase corev1.PodPhase, annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "test-namespace",
+			Labels:      map[string]string{"app": "web"},
+			Annotations: annotations,
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "web", Image: "web:1"},
+			{Name: "proxy", Image: "proxy:1"},
+		}},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func TestLauncherExec(t *testing.T) {
+	clientset := &customFakeClientset{Clientset: fake.NewSimpleClientset(
+		appPod("web-a", corev1.PodPending, nil),
+		appPod("web-b", corev1.PodRunning, map[string]string{defaultContainerAnnotation: "proxy"}),
+		appPod("web-c", corev1.PodRunning, nil),
+	)}
+	newLauncher := func() *Launcher {
+		return NewLauncher(
+			WithClientset(clientset),
+			WithRestConfig(&rest.Config{}),
+			WithExecutorFactory(&mockSPDYExecutorFactory{executor: &mockExecutor{stdout: "hello"}}),
+			WithNamespace("test-namespace"),
+			WithCommands("echo hello"),
+			WithOutputFile(""),
+			WithOutput(io.Discard, io.Discard),
+		)
+	}
+
+	tests := []struct {
+		name          string
+		opts          ExecOptions
+		wantPod       string
+		wantContainer string
+		wantImage     string
+	}{
+		{"by name", ExecOptions{Pod: "web-c"}, "web-c", "web", "web:1"},
+		{"by name and container", ExecOptions{Pod: "web-c", Container: "proxy"}, "web-c", "proxy", "proxy:1"},
+		{"by selector", ExecOptions{Selector: "app=web"}, "web-b", "proxy", "proxy:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := newLauncher().Exec(context.Background(), tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPod, result.PodName)
+			assert.Equal(t, tt.wantContainer, result.Container)
+			assert.Equal(t, tt.wantImage, result.Image)
+			assert.False(t, result.Deleted)
+			require.Len(t, result.Commands, 1)
+			assert.Equal(t, "hello", result.Commands[0].Stdout)
+		})
+	}
+
+	_, err := newLauncher().Exec(context.Background(), ExecOptions{Pod: "web-a"})
+	assert.Equal(t, ExitStartupFailed, ExitCode(err), "pending pods cannot be exec'd into")
+	_, err = newLauncher().Exec(context.Background(), ExecOptions{Selector: "app=db"})
+	assert.Equal(t, ExitLaunchFailed, ExitCode(err))
+	_, err = newLauncher().Exec(context.Background(), ExecOptions{})
+	assert.Error(t, err)
+
+	pods, err := clientset.CoreV1().Pods("test-namespace").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, pods.Items, 3, "exec neither creates nor deletes pods")
+}
+
+func TestLauncherExecInterrupted(t *testing.T) {
+	clientset := &customFakeClientset{Clientset: fake.NewSimpleClientset(appPod("web", corev1.PodRunning, nil))}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l := NewLauncher(
+		WithClientset(clientset),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(&mockSPDYExecutorFactory{executor: &cancelExecutor{cancel: cancel}}),
+		WithNamespace("test-namespace"),
+		WithCommands("sleep 60", "echo never"),
+		WithErrorPolicy(ContinueOnError),
+		WithOutputFile(""),
+	)
+	result, err := l.Exec(ctx, ExecOptions{Pod: "web"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, ExitInterrupted, ExitCode(err))
+	assert.Len(t, result.Commands, 1, "no command should start after the interrupt")
+}
@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+)
+
+// Exit statuses of the gopl command. When a single command ran in the pod
+// and exited non-zero, gopl exits with that command's status instead.
+const (
+	// ExitOK means the pod was launched, every command succeeded and the
+	// cleanup policy was applied.
+	ExitOK = 0
+	// ExitCommandFailed means one or more commands of a batch exited non-zero,
+	// or gopl failed for a reason not covered below.
+	ExitCommandFailed = 1
+	// ExitLaunchFailed means the pod could not be created, for example because
+	// the kubeconfig could not be loaded or the API server rejected the pod.
+	ExitLaunchFailed = 80
+	// ExitStartupFailed means the pod did not reach the Running phase, either
+	// because it failed or because the startup timeout expired.
+	ExitStartupFailed = 81
+	// ExitExecFailed means a command could not be executed in the pod at all.
+	ExitExecFailed = 82
+	// ExitCleanupFailed means the commands succeeded but the pod could not be
+	// deleted.
+	ExitCleanupFailed = 83
+	// ExitInterrupted means gopl was interrupted by SIGINT or SIGTERM.
+	ExitInterrupted = 130
+)
+
+// Stage is a step of the pod lifecycle driven by Launcher.Run.
+type Stage string
+
+const (
+	StageLaunch  Stage = "launch"
+	StageStartup Stage = "startup"
+	StageExec    Stage = "exec"
+	StageCleanup Stage = "cleanup"
+)
+
+// StageError is returned by Launcher.Run and records the lifecycle stage that
+// failed.
+type StageError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode maps an error returned by Launcher.Run to the exit status of the
+// gopl command.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var targetsErr *TargetsFailedError
+	if errors.As(err, &targetsErr) {
+		return targetsErr.ExitCode
+	}
+	if errors.Is(err, context.Canceled) {
+		return ExitInterrupted
+	}
+
+	var failedErr *CommandsFailedError
+	if errors.As(err, &failedErr) {
+		switch {
+		case failedErr.ExecErrors > 0:
+			return ExitExecFailed
+		case failedErr.Total == 1 && failedErr.LastExitCode > 0:
+			return failedErr.LastExitCode
+		default:
+			return ExitCommandFailed
+		}
+	}
+
+	var stageErr *StageError
+	if errors.As(err, &stageErr) {
+		switch stageErr.Stage {
+		case StageLaunch:
+			return ExitLaunchFailed
+		case StageStartup:
+			return ExitStartupFailed
+		case StageExec:
+			return ExitExecFailed
+		case StageCleanup:
+			return ExitCleanupFailed
+		}
+	}
+	return ExitCommandFailed
+}
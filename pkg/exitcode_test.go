@@ -0,0 +1,46 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", err: nil, want: ExitOK},
+		{name: "plain error", err: errors.New("boom"), want: ExitCommandFailed},
+		{name: "launch", err: &StageError{Stage: StageLaunch, Err: errors.New("forbidden")}, want: ExitLaunchFailed},
+		{name: "startup", err: &StageError{Stage: StageStartup, Err: errors.New("timeout")}, want: ExitStartupFailed},
+		{name: "cleanup", err: &StageError{Stage: StageCleanup, Err: errors.New("timeout")}, want: ExitCleanupFailed},
+		{
+			name: "single command exit code",
+			err: &StageError{Stage: StageExec, Err: fmt.Errorf("wrapped: %w",
+				&CommandsFailedError{Failed: 1, Run: 1, Total: 1, LastExitCode: 42})},
+			want: 42,
+		},
+		{
+			name: "several commands",
+			err: &StageError{Stage: StageExec,
+				Err: &CommandsFailedError{Failed: 1, Run: 2, Total: 2, LastExitCode: 42}},
+			want: ExitCommandFailed,
+		},
+		{
+			name: "command could not run",
+			err: &StageError{Stage: StageExec,
+				Err: &CommandsFailedError{Failed: 1, Run: 1, Total: 1, ExecErrors: 1, LastExitCode: -1}},
+			want: ExitExecFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExitCode(tt.err))
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"sigs.k8s.io/yaml"
+)
+
+// TargetResult is the outcome of Run against one kubeconfig context or node.
+type TargetResult struct {
+	Result *Result `json:"result"`
+	// Error describes why Run failed. It is empty when Run succeeded.
+	Error string `json:"error,omitempty"`
+	// ExitCode is the exit code of gopl for this target alone.
+	ExitCode int `json:"exitCode"`
+}
+
+// MultiResult describes a finished RunContexts or RunNodes.
+type MultiResult struct {
+	// Contexts holds the outcome of each kubeconfig context, keyed by name.
+	Contexts map[string]*TargetResult `json:"contexts,omitempty"`
+	// Nodes holds the outcome of each node, keyed by name.
+	Nodes map[string]*TargetResult `json:"nodes,omitempty"`
+}
+
+// TargetsFailedError reports that Run failed against some kubeconfig contexts
+// or nodes.
+type TargetsFailedError struct {
+	// Kind is "contexts" or "nodes".
+	Kind string
+	// Failed are the names of the failed targets, sorted.
+	Failed []string
+	// Total is the number of targets.
+	Total int
+	// ExitCode is the exit code shared by all failed targets, or
+	// ExitCommandFailed when they differ.
+	ExitCode int
+}
+
+func (e *TargetsFailedError) Error() string {
+	return fmt.Sprintf("%d of %d %s failed: %s", len(e.Failed), e.Total, e.Kind, strings.Join(e.Failed, ", "))
+}
+
+// fanOut runs the launcher returned by launcher for each target concurrently,
+// at most parallelism at a time; zero runs them all at once. The log lines of
+// each run are prefixed with its target.
+func fanOut(ctx context.Context, targets []string, parallelism int,
+	launcher func(target string) *Launcher) map[string]*TargetResult {
+	if parallelism <= 0 || parallelism > len(targets) {
+		parallelism = len(targets)
+	}
+
+	results := make(map[string]*TargetResult, len(targets))
+	var mu, logMu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallelism)
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			c := launcher(target)
+			c.log = &targetLog{mu: &logMu, w: c.log, prefix: []byte("[" + target + "] ")}
+			result, err := c.Run(ctx)
+			tr := &TargetResult{Result: result, ExitCode: ExitCode(err)}
+			if err != nil {
+				tr.Error = err.Error()
+			}
+			mu.Lock()
+			results[target] = tr
+			mu.Unlock()
+		}(target)
+	}
+	wg.Wait()
+	return results
+}
+
+// targetLog writes whole lines to w, prefixed with the name of a target of a
+// fan-out. mu is shared by the targets so that their lines do not interleave.
+type targetLog struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix []byte
+	// partial holds the start of a line not yet terminated.
+	partial []byte
+}
+
+func (t *targetLog) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.partial = append(t.partial, p...)
+	var lines []byte
+	for {
+		i := bytes.IndexByte(t.partial, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(append(lines, t.prefix...), t.partial[:i+1]...)
+		t.partial = t.partial[i+1:]
+	}
+	if len(lines) > 0 {
+		if _, err := t.w.Write(lines); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// forTarget returns a copy of the launcher for one of the targets of a fan-out.
+// Its output is not streamed and, with the text format, goes to output files
+// named after the target. Ports are not forwarded, since the targets would
+// compete for the same local ports.
+func (l *Launcher) forTarget(target string) *Launcher {
+	c := *l
+	c.stdout, c.stderr = nil, nil
+	c.forwards = nil
+	if c.outputFormat == FormatText && c.outputFile != "" {
+		c.outputFile += "." + invalidLabelChars.ReplaceAllString(target, "_")
+	} else {
+		c.outputFile = ""
+	}
+	return &c
+}
+
+// report writes the summary table of multi to the log writer and, with a
+// structured format, writes multi to the output file. It returns the error of
+// multi.
+func (l *Launcher) report(multi *MultiResult) error {
+	if err := WriteMultiResult(l.log, multi, FormatText); err != nil {
+		return err
+	}
+	if l.outputFormat != FormatText && l.outputFile != "" {
+		f, err := os.Create(l.outputFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := WriteMultiResult(f, multi, l.outputFormat); err != nil {
+			return err
+		}
+		l.logf("Output written to %s.\n", l.outputFile)
+	}
+	return multi.err()
+}
+
+// targets returns the kind of the targets of multi and their results.
+func (m *MultiResult) targets() (string, map[string]*TargetResult) {
+	if m.Nodes != nil {
+		return "nodes", m.Nodes
+	}
+	return "contexts", m.Contexts
+}
+
+func (m *MultiResult) err() error {
+	kind, results := m.targets()
+	e := &TargetsFailedError{Kind: kind, Total: len(results)}
+	for _, name := range sortedKeys(results) {
+		tr := results[name]
+		if tr.ExitCode == ExitOK {
+			continue
+		}
+		if len(e.Failed) == 0 {
+			e.ExitCode = tr.ExitCode
+		} else if e.ExitCode != tr.ExitCode {
+			e.ExitCode = ExitCommandFailed
+		}
+		e.Failed = append(e.Failed, name)
+	}
+	if len(e.Failed) == 0 {
+		return nil
+	}
+	return e
+}
+
+func sortedKeys(results map[string]*TargetResult) []string {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteMultiResult writes multi to w in the given format. The text format
+// writes a summary table with one row per context or node.
+func WriteMultiResult(w io.Writer, multi *MultiResult, format OutputFormat) error {
+	var out []byte
+	var err error
+	switch format {
+	case FormatText:
+		kind, results := multi.targets()
+		header := "CONTEXT"
+		if kind == "nodes" {
+			header = "NODE"
+		}
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "%s\tPOD\tCOMMANDS\tEXIT\tERROR\n", header)
+		for _, name := range sortedKeys(results) {
+			tr := results[name]
+			var pod string
+			var commands int
+			if tr.Result != nil {
+				pod, commands = tr.Result.PodName, len(tr.Result.Commands)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", name, pod, commands, tr.ExitCode, tr.Error)
+		}
+		return tw.Flush()
+	case FormatJSON:
+		out, err = json.MarshalIndent(multi, "", "  ")
+		out = append(out, '\n')
+	case FormatYAML:
+		out, err = yaml.Marshal(multi)
+	default:
+		_, err = ParseOutputFormat(string(format))
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
@@ -0,0 +1,209 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// GCOptions selects the pods reaped by Launcher.GC. Only pods carrying the
+// LabelManagedBy label are ever considered, and pods whose lease is still
+// being renewed are always kept. The Jobs of BackendJob are reaped with their
+// pods.
+type GCOptions struct {
+	// AllNamespaces looks in every namespace instead of the launcher's.
+	AllNamespaces bool
+	// OlderThan skips pods created less than this long ago.
+	OlderThan time.Duration
+	// User keeps only the pods launched by this user.
+	User string
+	// Phases keeps only the pods in one of these phases. Empty keeps all.
+	Phases []corev1.PodPhase
+	// DryRun lists the pods without deleting them.
+	DryRun bool
+	// Out receives the table of selected pods and the deletions. It may be
+	// nil.
+	Out io.Writer
+}
+
+// GCPod describes a pod selected by Launcher.GC.
+type GCPod struct {
+	Namespace string
+	// Name is empty for a Job whose pod is gone.
+	Name string
+	// Job is the Job of BackendJob owning the pod, if any. The Job is deleted
+	// instead of the pod, which goes with it.
+	Job      string
+	Phase    corev1.PodPhase
+	Age      time.Duration
+	User     string
+	Hostname string
+	// Deleted reports whether the pod, or its Job, was deleted.
+	Deleted bool
+}
+
+// ParsePodPhase validates s as a pod phase, for example Running.
+func ParsePodPhase(s string) (corev1.PodPhase, error) {
+	switch p := corev1.PodPhase(s); p {
+	case corev1.PodPending, corev1.PodRunning, corev1.PodSucceeded, corev1.PodFailed, corev1.PodUnknown:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown pod phase %q, must be one of %s, %s, %s, %s or %s", s,
+		corev1.PodPending, corev1.PodRunning, corev1.PodSucceeded, corev1.PodFailed, corev1.PodUnknown)
+}
+
+// GC deletes the pods and Jobs left behind by earlier runs, for example after
+// a crash or in vscode mode. Pods already being deleted are skipped. A Job
+// whose pod is gone is selected by age and user only, and never when
+// opts.Phases is set. Failing to delete one pod does not stop GC from deleting
+// the others.
+func (l *Launcher) GC(ctx context.Context, opts GCOptions) ([]GCPod, error) {
+	if err := l.init(); err != nil {
+		return nil, &StageError{Stage: StageLaunch, Err: err}
+	}
+	out := opts.Out
+	if out == nil {
+		out = io.Discard
+	}
+
+	namespace := l.namespace
+	if opts.AllNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+	selector := ManagedBySelector
+	if opts.User != "" {
+		selector += fmt.Sprintf(",%s=%s", LabelUser, labelValue(opts.User))
+	}
+	coreV1 := l.clientset.CoreV1()
+	list, err := coreV1.Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	jobList, err := l.clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	jobs := make(map[string]bool, len(jobList.Items))
+	for _, job := range jobList.Items {
+		jobs[job.Namespace+"/"+job.Name] = true
+	}
+
+	now := time.Now()
+	var pods []GCPod
+	jobsWithPods := make(map[string]bool)
+	for _, pod := range list.Items {
+		job := pod.Labels[LabelJob]
+		if jobs[pod.Namespace+"/"+job] {
+			jobsWithPods[pod.Namespace+"/"+job] = true
+		} else {
+			job = ""
+		}
+		age := now.Sub(pod.CreationTimestamp.Time)
+		if pod.DeletionTimestamp != nil || age < opts.OlderThan || !hasPhase(opts.Phases, pod.Status.Phase) {
+			continue
+		}
+		if expiry, ok := leaseExpiry(&pod); ok && now.Before(expiry) {
+			continue
+		}
+		pods = append(pods, GCPod{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			Job:       job,
+			Phase:     pod.Status.Phase,
+			Age:       age,
+			User:      pod.Annotations[AnnotationUser],
+			Hostname:  pod.Annotations[AnnotationHostname],
+		})
+	}
+	for _, job := range jobList.Items {
+		age := now.Sub(job.CreationTimestamp.Time)
+		if jobsWithPods[job.Namespace+"/"+job.Name] || job.DeletionTimestamp != nil || age < opts.OlderThan ||
+			len(opts.Phases) > 0 {
+			continue
+		}
+		pods = append(pods, GCPod{
+			Namespace: job.Namespace,
+			Job:       job.Name,
+			Age:       age,
+			User:      job.Annotations[AnnotationUser],
+			Hostname:  job.Annotations[AnnotationHostname],
+		})
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		if pods[i].Name != pods[j].Name {
+			return pods[i].Name < pods[j].Name
+		}
+		return pods[i].Job < pods[j].Job
+	})
+
+	if len(pods) == 0 {
+		fmt.Fprintln(out, "No pods to delete.")
+		return nil, nil
+	}
+	if err := writePodTable(out, pods); err != nil {
+		return pods, err
+	}
+	if opts.DryRun {
+		fmt.Fprintf(out, "%d pods would be deleted (dry run).\n", len(pods))
+		return pods, nil
+	}
+
+	var errs []error
+	for i := range pods {
+		p := &pods[i]
+		if p.Job != "" {
+			propagation := metav1.DeletePropagationBackground
+			err := l.clientset.BatchV1().Jobs(p.Namespace).Delete(ctx, p.Job, metav1.DeleteOptions{
+				PropagationPolicy: &propagation,
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete job %s in namespace %s: %w", p.Job, p.Namespace, err))
+				continue
+			}
+			p.Deleted = true
+			fmt.Fprintf(out, "Deleted job %s/%s.\n", p.Namespace, p.Job)
+			continue
+		}
+		err := coreV1.Pods(p.Namespace).Delete(ctx, p.Name, metav1.DeleteOptions{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete pod %s in namespace %s: %w", p.Name, p.Namespace, err))
+			continue
+		}
+		p.Deleted = true
+		fmt.Fprintf(out, "Deleted pod %s/%s.\n", p.Namespace, p.Name)
+	}
+	return pods, utilerrors.NewAggregate(errs)
+}
+
+func hasPhase(phases []corev1.PodPhase, phase corev1.PodPhase) bool {
+	if len(phases) == 0 {
+		return true
+	}
+	for _, p := range phases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+func writePodTable(out io.Writer, pods []GCPod) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tJOB\tPHASE\tAGE\tUSER\tHOST")
+	for _, p := range pods {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			p.Namespace, p.Name, p.Job, p.Phase, duration.HumanDuration(p.Age), p.User, p.Hostname)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,147 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func gcPod(namespace, name, user string, age time.Duration, phase corev1.PodPhase, managed bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         namespace,
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			Labels:            map[string]string{LabelUser: user},
+			Annotations:       map[string]string{AnnotationUser: user, AnnotationHostname: "laptop"},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+	if managed {
+		pod.Labels[LabelManagedBy] = ManagedBy
+	}
+	return pod
+}
+
+func newGCClientset() *fake.Clientset {
+	return fake.NewSimpleClientset(
+		gcPod("default", "old-running", "alice", 2*time.Hour, corev1.PodRunning, true),
+		gcPod("default", "old-failed", "bob", 3*time.Hour, corev1.PodFailed, true),
+		gcPod("default", "fresh", "alice", time.Minute, corev1.PodRunning, true),
+		gcPod("tools", "other-ns", "alice", 2*time.Hour, corev1.PodRunning, true),
+		gcPod("default", "unmanaged", "alice", 2*time.Hour, corev1.PodRunning, false),
+	)
+}
+
+func podNames(pods []GCPod) []string {
+	var names []string
+	for _, p := range pods {
+		names = append(names, p.Namespace+"/"+p.Name)
+	}
+	return names
+}
+
+func TestLauncherGC(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		opts GCOptions
+		want []string
+	}{
+		{"namespace", GCOptions{OlderThan: time.Hour}, []string{"default/old-failed", "default/old-running"}},
+		{"all namespaces", GCOptions{AllNamespaces: true, OlderThan: time.Hour},
+			[]string{"default/old-failed", "default/old-running", "tools/other-ns"}},
+		{"user", GCOptions{User: "alice"}, []string{"default/fresh", "default/old-running"}},
+		{"phase", GCOptions{Phases: []corev1.PodPhase{corev1.PodFailed}}, []string{"default/old-failed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := newGCClientset()
+			l := NewLauncher(WithClientset(clientset), WithRestConfig(&rest.Config{}))
+			pods, err := l.GC(ctx, tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, podNames(pods))
+			for _, p := range pods {
+				assert.True(t, p.Deleted)
+				_, err := clientset.CoreV1().Pods(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{})
+				assert.Error(t, err, "%s/%s should be deleted", p.Namespace, p.Name)
+			}
+			_, err = clientset.CoreV1().Pods("default").Get(ctx, "unmanaged", metav1.GetOptions{})
+			assert.NoError(t, err, "pods not launched by gopl are never deleted")
+		})
+	}
+}
+
+func TestLauncherGCDryRun(t *testing.T) {
+	ctx := context.Background()
+	clientset := newGCClientset()
+	var out bytes.Buffer
+
+	l := NewLauncher(WithClientset(clientset), WithRestConfig(&rest.Config{}))
+	pods, err := l.GC(ctx, GCOptions{OlderThan: time.Hour, DryRun: true, Out: &out})
+	assert.NoError(t, err)
+	assert.Len(t, pods, 2)
+	assert.False(t, pods[0].Deleted)
+	assert.Contains(t, out.String(), "NAMESPACE")
+	assert.Contains(t, out.String(), "old-running")
+	assert.Contains(t, out.String(), "2 pods would be deleted (dry run).")
+
+	_, err = clientset.CoreV1().Pods("default").Get(ctx, "old-running", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestLauncherGCJobs(t *testing.T) {
+	ctx := context.Background()
+	gcJob := func(name string, age time.Duration) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			Labels:            map[string]string{LabelManagedBy: ManagedBy, LabelUser: "alice"},
+			Annotations:       map[string]string{AnnotationUser: "alice"},
+		}}
+	}
+	jobPod := gcPod("default", "with-pod-abcde", "alice", 2*time.Hour, corev1.PodSucceeded, true)
+	jobPod.Labels[LabelJob] = "with-pod"
+	newClientset := func() *fake.Clientset {
+		return fake.NewSimpleClientset(jobPod,
+			gcJob("with-pod", 2*time.Hour), gcJob("without-pod", 2*time.Hour), gcJob("fresh", time.Minute))
+	}
+
+	clientset := newClientset()
+	l := NewLauncher(WithClientset(clientset), WithRestConfig(&rest.Config{}))
+	pods, err := l.GC(ctx, GCOptions{OlderThan: time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, []GCPod{
+		{Namespace: "default", Job: "without-pod", User: "alice"},
+		{Namespace: "default", Name: "with-pod-abcde", Job: "with-pod", Phase: corev1.PodSucceeded, User: "alice",
+			Hostname: "laptop"},
+	}, withoutAge(pods))
+	for _, name := range []string{"with-pod", "without-pod"} {
+		_, err := clientset.BatchV1().Jobs("default").Get(ctx, name, metav1.GetOptions{})
+		assert.Error(t, err, "job %s should be deleted", name)
+	}
+	_, err = clientset.BatchV1().Jobs("default").Get(ctx, "fresh", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	l = NewLauncher(WithClientset(newClientset()), WithRestConfig(&rest.Config{}))
+	pods, err = l.GC(ctx, GCOptions{Phases: []corev1.PodPhase{corev1.PodSucceeded}, DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"default/with-pod-abcde"}, podNames(pods), "jobs without a pod have no phase")
+}
+
+func withoutAge(pods []GCPod) []GCPod {
+	for i := range pods {
+		pods[i].Age, pods[i].Deleted = 0, false
+	}
+	return pods
+}
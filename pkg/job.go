@@ -0,0 +1,343 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
+	v1Inter "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// jobMarkerPrefix starts the lines delimiting the output of each command in
+// the logs of a Job. A random suffix keeps command output from faking them.
+const jobMarkerPrefix = "--gopl-"
+
+// runJob runs the commands as the script of a Job, waits for its pod to finish
+// and reads the output of each command from the pod logs. stdout and stderr
+// cannot be told apart in the logs, so both end up in Stdout.
+func (l *Launcher) runJob(ctx context.Context) (*Result, error) {
+	result := l.newResult()
+	if err := l.checkJobOptions(); err != nil {
+		return result, err
+	}
+	if err := l.init(); err != nil {
+		return result, &StageError{Stage: StageLaunch, Err: err}
+	}
+	coreV1 := l.clientset.CoreV1()
+	jobs := l.clientset.BatchV1().Jobs(l.namespace)
+
+	commands := l.resolveCommands()
+	marker := jobMarkerPrefix + utilrand.String(10)
+	job, err := jobs.Create(ctx, l.buildJob(commands, marker), metav1.CreateOptions{})
+	if err != nil {
+		return result, &StageError{Stage: StageLaunch, Err: fmt.Errorf("failed to create job in namespace %s: %w", l.namespace, err)}
+	}
+	result.Job = job.Name
+	result.Image = l.containerImage(&corev1.Pod{Spec: job.Spec.Template.Spec})
+	l.logf("Job created successfully. %s\n", job.Name)
+
+	var runErr *StageError
+	pod, err := waitForJobPod(ctx, coreV1, l.namespace, job.Name, l.startupTimeout, l.log)
+	if pod != nil {
+		result.PodName = pod.Name
+	}
+	if err != nil {
+		runErr = &StageError{Stage: StageStartup, Err: err}
+	} else if err := l.collectJobOutput(ctx, coreV1, pod.Name, commands, marker, result); err != nil {
+		runErr = &StageError{Stage: StageExec, Err: err}
+	}
+
+	interrupted := ctx.Err() != nil
+	if interrupted {
+		l.logf("Interrupted.\n")
+		if runErr != nil && !errors.Is(runErr, ctx.Err()) {
+			runErr.Err = fmt.Errorf("%w: %v", ctx.Err(), runErr.Err)
+		}
+	}
+
+	if l.shouldCleanup(runErr, interrupted) {
+		if err := l.cleanupJob(job.Name); err != nil {
+			if runErr == nil {
+				runErr = &StageError{Stage: StageCleanup, Err: err}
+			} else {
+				runErr.Err = fmt.Errorf("%w (cleanup also failed: %v)", runErr.Err, err)
+			}
+		} else {
+			result.Deleted = true
+			l.logf("Job deleted successfully.\n")
+		}
+	}
+
+	if runErr != nil {
+		return result, runErr
+	}
+	return result, nil
+}
+
+// checkJobOptions rejects the options the job backend cannot honour, rather
+// than running the commands without them.
+func (l *Launcher) checkJobOptions() error {
+	switch {
+	case l.script != nil:
+		return errors.New("scripts are not supported by the job backend")
+	case len(l.forwards) > 0:
+		return errors.New("port forwarding is not supported by the job backend")
+	case l.lease > 0:
+		return errors.New("leases are not supported by the job backend")
+	case l.onConflict != "":
+		return errors.New("conflict policies are not supported by the job backend")
+	}
+	// The Job controller puts the name in a label of the pod.
+	if msgs := validation.IsValidLabelValue(l.podName); len(msgs) > 0 {
+		return fmt.Errorf("invalid job name %q: %s", l.podName, strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// buildJob returns the Job running commands once, without retries. Its pod is
+// built like the pod of the exec backend, with the script as the command.
+func (l *Launcher) buildJob(commands []string, marker string) *batchv1.Job {
+	name := l.podName
+	if name == "" {
+		name = generateName(l.generateName, validation.LabelValueMaxLength)
+	}
+	cfg := l.podConfig()
+	cfg.podName = name
+	cfg.command = []string{"/bin/sh", "-c", jobScript(commands, marker, l.errorPolicy)}
+	cfg.labels, cfg.annotations = ownership(time.Now())
+	cfg.labels[LabelJob] = name
+	pod := buildPod(cfg)
+	pod.Spec.RestartPolicy = corev1.RestartPolicyNever
+
+	backoffLimit := int32(0)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   l.namespace,
+			Labels:      pod.Labels,
+			Annotations: pod.Annotations,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: pod.Labels, Annotations: pod.Annotations},
+				Spec:       pod.Spec,
+			},
+		},
+	}
+}
+
+// jobScript returns the shell script running each command with /bin/sh -c
+// between begin and end marker lines, the end line carrying the exit code. The
+// error policy is applied by the script itself.
+func jobScript(commands []string, marker string, policy ErrorPolicy) string {
+	var b strings.Builder
+	b.WriteString("failed=0\n")
+	for i, cmd := range commands {
+		fmt.Fprintf(&b, "printf '%%s begin %d\\n' %s\n", i, shellQuote(marker))
+		fmt.Fprintf(&b, "/bin/sh -c %s </dev/null 2>&1\n", shellQuote(cmd))
+		b.WriteString("rc=$?\n")
+		// The newline ends a last line without one; parseJobLog removes it.
+		fmt.Fprintf(&b, "printf '\\n%%s end %d %%d\\n' %s \"$rc\"\n", i, shellQuote(marker))
+		if policy.MaxFailures > 0 {
+			fmt.Fprintf(&b, "if [ \"$rc\" -ne 0 ]; then failed=$((failed+1)); [ \"$failed\" -lt %d ] || exit 0; fi\n",
+				policy.MaxFailures)
+		}
+	}
+	return b.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// parseJobLog splits logs read with timestamps into the results of commands,
+// using the marker lines written by jobScript. A command that began but did
+// not end, because the pod was killed, is recorded with exit code -1.
+func parseJobLog(r io.Reader, marker string, commands []string) ([]CommandResult, error) {
+	var results []CommandResult
+	var current *CommandResult
+	var output strings.Builder
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			timestamp, content := splitLogTimestamp(line)
+			fields := strings.Fields(content)
+			switch {
+			case len(fields) == 3 && fields[0] == marker && fields[1] == "begin":
+				i, convErr := strconv.Atoi(fields[2])
+				if convErr != nil || i < 0 || i >= len(commands) {
+					return results, fmt.Errorf("malformed marker line %q", content)
+				}
+				current = &CommandResult{Command: commands[i], StartTime: metav1.NewTime(timestamp)}
+				output.Reset()
+			case len(fields) == 4 && fields[0] == marker && fields[1] == "end" && current != nil:
+				code, convErr := strconv.Atoi(fields[3])
+				if convErr != nil {
+					return results, fmt.Errorf("malformed marker line %q", content)
+				}
+				current.Stdout = strings.TrimSuffix(output.String(), "\n")
+				current.ExitCode = code
+				current.EndTime = metav1.NewTime(timestamp)
+				current.Duration = metav1.Duration{Duration: timestamp.Sub(current.StartTime.Time)}
+				results = append(results, *current)
+				current = nil
+			case current != nil:
+				output.WriteString(content)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return results, err
+		}
+	}
+
+	if current != nil {
+		current.Stdout = output.String()
+		current.ExitCode = -1
+		current.Error = "command did not finish"
+		results = append(results, *current)
+	}
+	return results, nil
+}
+
+// splitLogTimestamp splits a log line read with timestamps into its time and
+// content. Lines without a timestamp are returned whole, at the current time.
+func splitLogTimestamp(line string) (time.Time, string) {
+	if i := strings.IndexByte(line, ' '); i > 0 {
+		if t, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+			return t, line[i+1:]
+		}
+	}
+	return time.Now(), line
+}
+
+// collectJobOutput records the results of the commands from the logs of the
+// pod, copying their output to the terminal and the text output files.
+func (l *Launcher) collectJobOutput(ctx context.Context, coreV1 v1Inter.CoreV1Interface, podName string,
+	commands []string, marker string, result *Result) error {
+	logs, err := coreV1.Pods(l.namespace).GetLogs(podName, &corev1.PodLogOptions{
+		Container:  l.containerName,
+		Timestamps: true,
+	}).Stream(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read the logs of pod %s: %w", podName, err)
+	}
+	defer logs.Close()
+	result.Commands, err = parseJobLog(logs, marker, commands)
+	if err != nil {
+		return fmt.Errorf("failed to read the logs of pod %s: %w", podName, err)
+	}
+
+	live, closeLive, err := l.openLiveOutput()
+	if err != nil {
+		return err
+	}
+	defer closeLive()
+	failedErr := &CommandsFailedError{Total: len(commands), Run: len(result.Commands)}
+	for _, r := range result.Commands {
+		stdout, _ := live.start(r.Command)
+		if _, err := io.WriteString(stdout, r.Stdout); err != nil {
+			return err
+		}
+		if err := live.finish(); err != nil {
+			return err
+		}
+		if !r.Succeeded() {
+			failedErr.Failed++
+			failedErr.LastExitCode = r.ExitCode
+			if r.Error != "" {
+				failedErr.ExecErrors++
+			}
+			l.logf("Command %q failed with exit code %d\n", r.Command, r.ExitCode)
+		}
+	}
+	if failedErr.Failed > 0 {
+		return failedErr
+	}
+	if failedErr.Run < failedErr.Total {
+		return fmt.Errorf("only %d of %d commands ran in pod %s", failedErr.Run, failedErr.Total, podName)
+	}
+	return nil
+}
+
+// waitForJobPod watches the pod of the Job until it has finished. It fails as
+// soon as the pod cannot start, and with the last known problem when the pod is
+// not running once startupTimeout expires. A zero startupTimeout waits until
+// ctx is done. Progress is written to log, which may be nil.
+func waitForJobPod(ctx context.Context, coreV1 v1Inter.CoreV1Interface, namespace, jobName string,
+	startupTimeout time.Duration, log io.Writer) (*corev1.Pod, error) {
+	var timeoutCh <-chan time.Time
+	if startupTimeout > 0 {
+		timer := time.NewTimer(startupTimeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	var pod *corev1.Pod
+	running := false
+	lastIssue := &PodStartError{PodName: jobName, Namespace: namespace, Failure: StartTimeout}
+	check := func(p *corev1.Pod) (bool, error) {
+		pod = p
+		switch p.Status.Phase {
+		case corev1.PodSucceeded, corev1.PodFailed:
+			return true, nil
+		case corev1.PodRunning:
+			if !running {
+				logf(log, "Pod is running.\n")
+				running, timeoutCh = true, nil
+			}
+			return false, nil
+		}
+		_, issue := diagnosePod(p)
+		switch {
+		case issue == nil:
+		case issue.fatal():
+			return true, issue
+		default:
+			lastIssue = issue
+		}
+		return false, nil
+	}
+
+	options := metav1.ListOptions{LabelSelector: LabelJob + "=" + jobName}
+	err := watchPods(ctx, coreV1.Pods(namespace), options, func() ([]corev1.Pod, error) {
+		pods, err := coreV1.Pods(namespace).List(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		return pods.Items, nil
+	}, &timeoutCh, check)
+	if err == errWaitTimeout {
+		lastIssue.TimedOut = true
+		return pod, lastIssue
+	}
+	return pod, err
+}
+
+// cleanupJob deletes the Job and its pod, bounded by the delete timeout.
+func (l *Launcher) cleanupJob(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.deleteTimeout)
+	defer cancel()
+
+	propagation := metav1.DeletePropagationForeground
+	err := l.clientset.BatchV1().Jobs(l.namespace).Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil {
+		return fmt.Errorf("failed to delete job %s in namespace %s: %w", name, l.namespace, err)
+	}
+	return nil
+}
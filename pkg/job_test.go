@@ -0,0 +1,198 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	v1Inter "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	fakerest "k8s.io/client-go/rest/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// runJobScript runs the script of a Job with the local shell and returns its
+// output as the pod logs would with timestamps.
+func runJobScript(t *testing.T, script string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell to run the job script")
+	}
+	out, err := exec.Command("sh", "-c", script).Output()
+	require.NoError(t, err)
+
+	var logs strings.Builder
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, line := range strings.SplitAfter(string(out), "\n") {
+		if line == "" {
+			continue
+		}
+		logs.WriteString(timestamp.Format(time.RFC3339Nano) + " " + line)
+		timestamp = timestamp.Add(time.Second)
+	}
+	return logs.String()
+}
+
+func TestJobScript(t *testing.T) {
+	commands := []string{"echo hello", "printf 'no newline'", "echo oops >&2; exit 3", "echo skipped"}
+	marker := jobMarkerPrefix + "test"
+
+	logs := runJobScript(t, jobScript(commands, marker, FailFast))
+	results, err := parseJobLog(strings.NewReader(logs), marker, commands)
+	require.NoError(t, err)
+	require.Len(t, results, 3, "fail-fast should skip the last command")
+
+	assert.Equal(t, "echo hello", results[0].Command)
+	assert.Equal(t, "hello\n", results[0].Stdout)
+	assert.Equal(t, 0, results[0].ExitCode)
+	assert.Equal(t, 3*time.Second, results[0].Duration.Duration, "begin, output, newline and end lines are a second apart")
+	assert.Equal(t, "no newline", results[1].Stdout)
+	assert.Equal(t, "oops\n", results[2].Stdout, "stderr is part of the logs")
+	assert.Equal(t, 3, results[2].ExitCode)
+
+	logs = runJobScript(t, jobScript(commands, marker, ContinueOnError))
+	results, err = parseJobLog(strings.NewReader(logs), marker, commands)
+	require.NoError(t, err)
+	assert.Len(t, results, 4)
+}
+
+func TestParseJobLogUnfinished(t *testing.T) {
+	logs := "2024-05-01T12:00:00Z --gopl-x begin 0\n" +
+		"2024-05-01T12:00:01Z partial\n"
+	results, err := parseJobLog(strings.NewReader(logs), "--gopl-x", []string{"sleep 600"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, -1, results[0].ExitCode)
+	assert.Equal(t, "partial\n", results[0].Stdout)
+	assert.NotEmpty(t, results[0].Error)
+	assert.False(t, results[0].Succeeded())
+
+	_, err = parseJobLog(strings.NewReader("--gopl-x begin 7\n"), "--gopl-x", []string{"true"})
+	assert.Error(t, err)
+}
+
+// jobClientset runs the script of each created Job with the local shell and
+// serves its output as the logs of the Job's pod.
+type jobClientset struct {
+	*fake.Clientset
+	logs string
+}
+
+func newJobClientset(t *testing.T) *jobClientset {
+	c := &jobClientset{Clientset: fake.NewSimpleClientset()}
+	c.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		c.logs = runJobScript(t, job.Spec.Template.Spec.Containers[0].Command[2])
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      job.Name + "-abcde",
+				Namespace: job.Namespace,
+				Labels:    job.Spec.Template.Labels,
+			},
+			Spec:   job.Spec.Template.Spec,
+			Status: corev1.PodStatus{Phase: corev1.PodSucceeded},
+		}
+		return false, nil, c.Tracker().Add(pod)
+	})
+	return c
+}
+
+func (c *jobClientset) CoreV1() v1Inter.CoreV1Interface {
+	return &logsCoreV1{CoreV1Interface: c.Clientset.CoreV1(), clientset: c}
+}
+
+type logsCoreV1 struct {
+	v1Inter.CoreV1Interface
+	clientset *jobClientset
+}
+
+func (c *logsCoreV1) Pods(namespace string) v1Inter.PodInterface {
+	return &logsPods{PodInterface: c.CoreV1Interface.Pods(namespace), clientset: c.clientset}
+}
+
+type logsPods struct {
+	v1Inter.PodInterface
+	clientset *jobClientset
+}
+
+func (p *logsPods) GetLogs(name string, opts *corev1.PodLogOptions) *rest.Request {
+	client := &fakerest.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Client: fakerest.CreateHTTPClient(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(p.clientset.logs))}, nil
+		}),
+	}
+	return client.Get()
+}
+
+func TestLauncherRunJob(t *testing.T) {
+	ctx := context.Background()
+	clientset := newJobClientset(t)
+	var stdout strings.Builder
+
+	l := NewLauncher(
+		WithClientset(clientset),
+		WithRestConfig(&rest.Config{}),
+		WithBackend(BackendJob),
+		WithNamespace("test-namespace"),
+		WithCommands("echo hello", "exit 4"),
+		WithOutputFile(""),
+		WithOutput(&stdout, io.Discard),
+	)
+	result, err := l.Run(ctx)
+	assert.Equal(t, ExitCommandFailed, ExitCode(err), "%v", err)
+	assert.Regexp(t, `^aws-cli-pod-[a-z0-9]{5}$`, result.Job)
+	assert.Equal(t, result.Job+"-abcde", result.PodName)
+	assert.Equal(t, defaultImage, result.Image)
+	assert.True(t, result.Deleted)
+	require.Len(t, result.Commands, 2)
+	assert.Equal(t, "hello\n", result.Commands[0].Stdout)
+	assert.Equal(t, 4, result.Commands[1].ExitCode)
+	assert.Equal(t, "hello\n", stdout.String())
+
+	_, err = clientset.BatchV1().Jobs("test-namespace").Get(ctx, result.Job, metav1.GetOptions{})
+	assert.Error(t, err, "the job should be deleted")
+}
+
+func TestLauncherRunJobRejectsOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option
+		err  string
+	}{
+		{"forward", WithPortForward("8080"), "port forwarding"},
+		{"lease", WithLease(MinLease), "leases"},
+		{"conflict", WithConflictPolicy(ConflictReuse), "conflict policies"},
+		{"long name", WithPodName(strings.Repeat("a", 64)), "invalid job name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := newJobClientset(t)
+			l := NewLauncher(
+				WithClientset(clientset),
+				WithRestConfig(&rest.Config{}),
+				WithBackend(BackendJob),
+				WithNamespace("test-namespace"),
+				WithCommands("echo hello"),
+				tt.opt,
+			)
+			_, err := l.Run(context.Background())
+			assert.ErrorContains(t, err, tt.err)
+			jobs, err := clientset.BatchV1().Jobs("test-namespace").List(context.Background(), metav1.ListOptions{})
+			require.NoError(t, err)
+			assert.Empty(t, jobs.Items, "no job should be created")
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// KubeConfig selects the cluster and the credentials used to reach it, like
+// the kubectl flags of the same names. The zero value loads the kubeconfig
+// files named by KUBECONFIG, or ~/.kube/config, and falls back to the
+// in-cluster config when there are none.
+type KubeConfig struct {
+	// Kubeconfig is the path of the kubeconfig file, overriding KUBECONFIG.
+	Kubeconfig string
+	// Context is the kubeconfig context to use instead of the current one.
+	Context string
+	// Cluster is the kubeconfig cluster to use instead of the context's.
+	Cluster string
+	// User is the kubeconfig user to use instead of the context's.
+	User string
+	// Impersonate is the user to act as.
+	Impersonate string
+	// ImpersonateGroups are the groups to act as.
+	ImpersonateGroups []string
+}
+
+// RestConfig loads the REST config used both for the API calls and for the
+// exec and port-forward streams.
+func (k KubeConfig) RestConfig() (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = k.Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: k.Context}
+	overrides.Context.Cluster = k.Cluster
+	overrides.Context.AuthInfo = k.User
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Kubernetes configuration: %w", err)
+	}
+	if k.Impersonate != "" || len(k.ImpersonateGroups) > 0 {
+		config.Impersonate = rest.ImpersonationConfig{
+			UserName: k.Impersonate,
+			Groups:   k.ImpersonateGroups,
+		}
+	}
+	return config, nil
+}
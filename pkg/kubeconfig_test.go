@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com
+- name: prod
+  cluster:
+    server: https://prod.example.com
+users:
+- name: alice
+  user:
+    token: alice-token
+- name: bob
+  user:
+    token: bob-token
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: alice
+- name: prod
+  context:
+    cluster: prod
+    user: alice
+`
+
+func TestKubeConfigRestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(testKubeconfig), 0o600))
+
+	config, err := KubeConfig{Kubeconfig: path}.RestConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://dev.example.com", config.Host)
+	assert.Equal(t, "alice-token", config.BearerToken)
+	assert.Empty(t, config.Impersonate.UserName)
+
+	config, err = KubeConfig{Kubeconfig: path, Context: "prod", User: "bob"}.RestConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://prod.example.com", config.Host)
+	assert.Equal(t, "bob-token", config.BearerToken)
+
+	config, err = KubeConfig{Kubeconfig: path, Cluster: "prod", Impersonate: "carol",
+		ImpersonateGroups: []string{"admins"}}.RestConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://prod.example.com", config.Host)
+	assert.Equal(t, "carol", config.Impersonate.UserName)
+	assert.Equal(t, []string{"admins"}, config.Impersonate.Groups)
+
+	_, err = KubeConfig{Kubeconfig: path, Context: "missing"}.RestConfig()
+	assert.Error(t, err)
+}
+
+func TestListContexts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(testKubeconfig), 0o600))
+
+	names, err := ListContexts(KubeConfig{Kubeconfig: path})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev", "prod"}, names)
+}
@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cwxstat/go-pod-launch-run/constants"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Labels and annotations gopl puts on every pod it creates, so that pods can
+// be traced back to whoever launched them.
+const (
+	// LabelManagedBy is set to ManagedBy on every pod gopl creates.
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	// ManagedBy is the value of LabelManagedBy.
+	ManagedBy = "gopl"
+	// LabelVersion holds the gopl version that created the pod.
+	LabelVersion = "app.kubernetes.io/version"
+	// LabelUser holds the invoking user, reduced to a valid label value.
+	LabelUser = "gopl.cwxstat.github.io/user"
+	// LabelJob holds the name of the Job a pod was created for.
+	LabelJob = "gopl.cwxstat.github.io/job"
+
+	// AnnotationUser holds the invoking user as reported by the OS.
+	AnnotationUser = "gopl.cwxstat.github.io/user"
+	// AnnotationHostname holds the host gopl ran on.
+	AnnotationHostname = "gopl.cwxstat.github.io/hostname"
+	// AnnotationStartedAt holds the launch time in RFC 3339 format.
+	AnnotationStartedAt = "gopl.cwxstat.github.io/started-at"
+)
+
+// ManagedBySelector selects the pods created by gopl.
+const ManagedBySelector = LabelManagedBy + "=" + ManagedBy
+
+// ownership returns the labels and annotations identifying the invoking user,
+// host and gopl version.
+func ownership(now time.Time) (map[string]string, map[string]string) {
+	username := currentUser()
+	hostname, _ := os.Hostname()
+
+	labels := map[string]string{
+		LabelManagedBy: ManagedBy,
+		LabelVersion:   labelValue(constants.VERSION),
+	}
+	if v := labelValue(username); v != "" {
+		labels[LabelUser] = v
+	}
+	annotations := map[string]string{
+		AnnotationUser:      username,
+		AnnotationHostname:  hostname,
+		AnnotationStartedAt: now.UTC().Format(time.RFC3339),
+	}
+	return labels, annotations
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// labelValue reduces s to a valid label value, for example "CORP\jdoe" to
+// "CORP-jdoe".
+func labelValue(s string) string {
+	s = invalidLabelChars.ReplaceAllString(s, "-")
+	if len(s) > validation.LabelValueMaxLength {
+		s = s[:validation.LabelValueMaxLength]
+	}
+	return strings.Trim(s, "-_.")
+}
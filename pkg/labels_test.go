@@ -0,0 +1,41 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestLabelValue(t *testing.T) {
+	assert.Equal(t, "jdoe", labelValue("jdoe"))
+	assert.Equal(t, "CORP-jdoe", labelValue(`CORP\jdoe`))
+	assert.Equal(t, "jane-doe", labelValue("jane doe@"))
+	assert.Empty(t, labelValue(""))
+
+	long := labelValue(strings.Repeat("a", 100))
+	assert.Len(t, long, validation.LabelValueMaxLength)
+}
+
+func TestBuildPodOwnershipMetadata(t *testing.T) {
+	labels, annotations := ownership(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, "2024-05-01T12:00:00Z", annotations[AnnotationStartedAt])
+
+	pod := buildPod(podConfig{
+		namespace:     "default",
+		podName:       "debug-pod",
+		containerName: "debug",
+		template: &corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "infra", LabelManagedBy: "helm"}},
+		},
+		labels:      labels,
+		annotations: annotations,
+	})
+	assert.Equal(t, "infra", pod.Labels["team"], "template labels are kept")
+	assert.Equal(t, ManagedBy, pod.Labels[LabelManagedBy], "ownership labels win over the template")
+	assert.Equal(t, annotations, pod.Annotations)
+}
@@ -0,0 +1,855 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cwxstat/go-pod-launch-run/pkg/term"
+	"github.com/cwxstat/go-pod-launch-run/pkg/vscode"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	v1Inter "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	defaultPodNameBase   = "aws-cli-pod"
+	defaultNamespace     = "default"
+	defaultContainerName = "aws-cli"
+	defaultImage         = "amazon/aws-cli:latest"
+)
+
+var defaultCommands = []string{"aws configure list", "aws sts get-caller-identity"}
+
+var defaultKeepaliveCommand = []string{"sleep", "3600"}
+
+// CleanupPolicy decides whether the launched pod is deleted once Run is done.
+type CleanupPolicy string
+
+const (
+	// CleanupAlways deletes the pod whatever the outcome of the commands.
+	CleanupAlways CleanupPolicy = "always"
+	// CleanupOnSuccess deletes the pod only when every step succeeded, leaving
+	// it behind for inspection otherwise.
+	CleanupOnSuccess CleanupPolicy = "on-success"
+	// CleanupNever leaves the pod running.
+	CleanupNever CleanupPolicy = "never"
+)
+
+// ParseImagePullPolicy validates s as an image pull policy. The empty string
+// leaves the choice to the cluster.
+func ParseImagePullPolicy(s string) (corev1.PullPolicy, error) {
+	switch p := corev1.PullPolicy(s); p {
+	case "", corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown image pull policy %q, must be one of %s, %s or %s",
+		s, corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever)
+}
+
+// ParseCleanupPolicy validates s as a CleanupPolicy.
+func ParseCleanupPolicy(s string) (CleanupPolicy, error) {
+	switch p := CleanupPolicy(s); p {
+	case CleanupAlways, CleanupOnSuccess, CleanupNever:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown cleanup policy %q, must be one of %s, %s or %s",
+		s, CleanupAlways, CleanupOnSuccess, CleanupNever)
+}
+
+// Backend selects how the commands are run.
+type Backend string
+
+const (
+	// BackendExec launches a pod kept alive by the keepalive command and runs
+	// each command in it with pods/exec.
+	BackendExec Backend = "exec"
+	// BackendJob bakes the commands into the script of a batch/v1 Job and
+	// collects their output from the pod logs, for clusters that forbid
+	// pods/exec.
+	BackendJob Backend = "job"
+)
+
+// ParseBackend validates s as a Backend.
+func ParseBackend(s string) (Backend, error) {
+	switch b := Backend(s); b {
+	case BackendExec, BackendJob:
+		return b, nil
+	}
+	return "", fmt.Errorf("unknown backend %q, must be %s or %s", s, BackendExec, BackendJob)
+}
+
+// ConflictPolicy decides what happens when a pod with the requested name
+// already exists.
+type ConflictPolicy string
+
+const (
+	// ConflictAsk asks whether to replace the pod when stdin is a terminal,
+	// and fails otherwise.
+	ConflictAsk ConflictPolicy = "ask"
+	// ConflictReuse runs the commands in the existing pod, which is then
+	// left in place.
+	ConflictReuse ConflictPolicy = "reuse"
+	// ConflictReplace deletes the existing pod, waits for it to be gone and
+	// creates a new one.
+	ConflictReplace ConflictPolicy = "replace"
+	// ConflictFail fails the launch.
+	ConflictFail ConflictPolicy = "fail"
+	// ConflictGenerate retries with a random suffix appended to the pod name.
+	ConflictGenerate ConflictPolicy = "generate"
+)
+
+// ParseConflictPolicy validates s as a ConflictPolicy.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case ConflictAsk, ConflictReuse, ConflictReplace, ConflictFail, ConflictGenerate:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q, must be one of %s, %s, %s, %s or %s",
+		s, ConflictAsk, ConflictReuse, ConflictReplace, ConflictFail, ConflictGenerate)
+}
+
+// maxGenerateAttempts bounds the names tried by ConflictGenerate.
+const maxGenerateAttempts = 5
+
+// generatePodName appends a random suffix to base, the way the API server
+// handles metadata.generateName.
+func generatePodName(base string) string {
+	return generateName(base, validation.DNS1123SubdomainMaxLength)
+}
+
+// generateName appends a random suffix to base, truncating base so that the
+// name is at most maxLength long.
+func generateName(base string, maxLength int) string {
+	maxBaseLength := maxLength - 6
+	if len(base) > maxBaseLength {
+		base = strings.TrimRight(base[:maxBaseLength], "-.")
+	}
+	return fmt.Sprintf("%s-%s", base, utilrand.String(5))
+}
+
+// Launcher launches a pod, runs a batch of commands in it and cleans up
+// afterwards. Build one with NewLauncher.
+type Launcher struct {
+	podName            string
+	generateName       string
+	namespace          string
+	containerName      string
+	serviceAccountName string
+	nodeName           string
+	backend            Backend
+	image              string
+	imagePullPolicy    corev1.PullPolicy
+	keepaliveCommand   []string
+	podTemplate        *corev1.PodTemplateSpec
+	commands           []string
+	script             *Script
+	errorPolicy        ErrorPolicy
+	vscodeDebug        bool
+
+	outputFile   string
+	outputFormat OutputFormat
+	stdout       io.Writer
+	stderr       io.Writer
+	outputPrefix bool
+
+	startupTimeout       time.Duration
+	ttl                  time.Duration
+	lease                time.Duration
+	deleteTimeout        time.Duration
+	interruptGracePeriod time.Duration
+	cleanup              CleanupPolicy
+	onConflict           ConflictPolicy
+
+	forwards []string
+
+	clientset            kubernetes.Interface
+	kubeConfig           KubeConfig
+	restConfig           *rest.Config
+	executorFactory      SPDYExecutorFactory
+	portForwarderFactory PortForwarderFactory
+
+	log io.Writer
+}
+
+// Option configures a Launcher.
+type Option func(*Launcher)
+
+// WithPodName sets the name of the launched pod. When empty, the default, a
+// unique name is generated by appending a random suffix to the name set by
+// WithGenerateName.
+func WithPodName(name string) Option {
+	return func(l *Launcher) {
+		l.podName = name
+	}
+}
+
+// WithGenerateName sets the base of generated pod names. The default is
+// aws-cli-pod.
+func WithGenerateName(base string) Option {
+	return func(l *Launcher) {
+		l.generateName = base
+	}
+}
+
+// WithNamespace sets the namespace the pod is launched in.
+func WithNamespace(namespace string) Option {
+	return func(l *Launcher) {
+		l.namespace = namespace
+	}
+}
+
+// WithContainerName sets the name of the container commands are executed in.
+func WithContainerName(name string) Option {
+	return func(l *Launcher) {
+		l.containerName = name
+	}
+}
+
+// WithServiceAccount sets the service account the pod runs as. By default it
+// comes from the pod template, or is the default service account of the
+// namespace.
+func WithServiceAccount(name string) Option {
+	return func(l *Launcher) {
+		l.serviceAccountName = name
+	}
+}
+
+// WithBackend selects how the commands are run. The default is BackendExec.
+func WithBackend(backend Backend) Option {
+	return func(l *Launcher) {
+		l.backend = backend
+	}
+}
+
+// WithNodeName runs the pod on the named node, bypassing the scheduler. By
+// default it comes from the pod template, or the pod is scheduled anywhere.
+func WithNodeName(name string) Option {
+	return func(l *Launcher) {
+		l.nodeName = name
+	}
+}
+
+// WithImage sets the container image of the launched pod. By default it comes
+// from the pod template, or is the AWS CLI image.
+func WithImage(image string) Option {
+	return func(l *Launcher) {
+		l.image = image
+	}
+}
+
+// WithImagePullPolicy sets the pull policy of the container image. When
+// empty the cluster default applies.
+func WithImagePullPolicy(policy corev1.PullPolicy) Option {
+	return func(l *Launcher) {
+		l.imagePullPolicy = policy
+	}
+}
+
+// WithKeepaliveCommand sets the container command that keeps the pod running
+// while commands are executed in it. It must not exit before they are done.
+// By default it comes from the pod template, or is sleep 3600.
+func WithKeepaliveCommand(command ...string) Option {
+	return func(l *Launcher) {
+		l.keepaliveCommand = command
+	}
+}
+
+// WithPodTemplate launches the pod from template, see LoadPodTemplate. The
+// pod name, namespace and the other Launcher options are merged into it;
+// options left unset keep the values of the template.
+func WithPodTemplate(template *corev1.PodTemplateSpec) Option {
+	return func(l *Launcher) {
+		l.podTemplate = template
+	}
+}
+
+// WithCommands sets the commands run in the pod. Each command is run with
+// /bin/sh -c. When no commands are given the AWS CLI defaults are used.
+func WithCommands(commands ...string) Option {
+	return func(l *Launcher) {
+		l.commands = commands
+	}
+}
+
+// WithScript runs a local script in the pod instead of the commands. The
+// script is streamed over the exec stdin, so that shell state carries across
+// its lines, and recorded as a single CommandResult. It is not supported by
+// BackendJob.
+func WithScript(script *Script) Option {
+	return func(l *Launcher) {
+		l.script = script
+	}
+}
+
+// WithErrorPolicy sets whether the remaining commands run after a command
+// failed. The default is FailFast.
+func WithErrorPolicy(policy ErrorPolicy) Option {
+	return func(l *Launcher) {
+		l.errorPolicy = policy
+	}
+}
+
+// WithVscodeDebug replaces the commands with the vscode setup commands and
+// leaves the pod running so code-server can be used from it.
+func WithVscodeDebug(enabled bool) Option {
+	return func(l *Launcher) {
+		l.vscodeDebug = enabled
+		if enabled {
+			l.cleanup = CleanupNever
+		}
+	}
+}
+
+// WithOutputFile writes the outcome of the commands to path, in the format set
+// by WithOutputFormat. Text output is written while the commands run, the
+// structured formats once they are done.
+func WithOutputFile(path string) Option {
+	return func(l *Launcher) {
+		l.outputFile = path
+	}
+}
+
+// WithOutputFormat sets the format of the output file. With FormatText,
+// stdout of all commands goes to the output file and stderr to the output
+// file with an .err suffix.
+func WithOutputFormat(format OutputFormat) Option {
+	return func(l *Launcher) {
+		l.outputFormat = format
+	}
+}
+
+// WithOutput streams stdout and stderr of the commands to the given writers
+// while they run. Either writer may be nil.
+func WithOutput(stdout, stderr io.Writer) Option {
+	return func(l *Launcher) {
+		l.stdout = stdout
+		l.stderr = stderr
+	}
+}
+
+// WithLog sets the writer progress messages, such as pod events and the
+// steps of the lifecycle, are written to. By default, or when w is nil, they
+// are discarded.
+func WithLog(w io.Writer) Option {
+	return func(l *Launcher) {
+		if w == nil {
+			w = io.Discard
+		}
+		l.log = w
+	}
+}
+
+// WithOutputPrefix prefixes every line streamed to the WithOutput writers
+// with the command that produced it.
+func WithOutputPrefix(enabled bool) Option {
+	return func(l *Launcher) {
+		l.outputPrefix = enabled
+	}
+}
+
+// WithStartupTimeout bounds how long Run waits for the pod to be running. The
+// default is five minutes; zero waits forever.
+func WithStartupTimeout(d time.Duration) Option {
+	return func(l *Launcher) {
+		l.startupTimeout = d
+	}
+}
+
+// WithTTL bounds the lifetime of the pod with activeDeadlineSeconds, so that
+// the cluster kills it even when gopl never deletes it. Zero, the default,
+// leaves the lifetime to the keepalive command.
+func WithTTL(d time.Duration) Option {
+	return func(l *Launcher) {
+		l.ttl = d
+	}
+}
+
+// WithLease puts a lease on the pod that is renewed while the session is alive.
+// GC never deletes a pod whose lease has not expired. Zero, the default, puts
+// no lease; a shorter lease than MinLease is raised to MinLease. It is not
+// supported by BackendJob.
+func WithLease(d time.Duration) Option {
+	return func(l *Launcher) {
+		if d > 0 && d < MinLease {
+			d = MinLease
+		}
+		l.lease = d
+	}
+}
+
+// WithDeleteTimeout bounds how long Run waits for the pod to be deleted.
+func WithDeleteTimeout(d time.Duration) Option {
+	return func(l *Launcher) {
+		l.deleteTimeout = d
+	}
+}
+
+// WithInterruptGracePeriod sets the termination grace period used when the
+// pod is deleted because the context of Run was cancelled.
+func WithInterruptGracePeriod(d time.Duration) Option {
+	return func(l *Launcher) {
+		l.interruptGracePeriod = d
+	}
+}
+
+// WithConflictPolicy sets what happens when the pod already exists. Run
+// defaults to ConflictAsk and Shell to ConflictReuse. It is not supported by
+// BackendJob.
+func WithConflictPolicy(policy ConflictPolicy) Option {
+	return func(l *Launcher) {
+		l.onConflict = policy
+	}
+}
+
+// WithCleanupPolicy sets when the pod is deleted.
+func WithCleanupPolicy(policy CleanupPolicy) Option {
+	return func(l *Launcher) {
+		l.cleanup = policy
+	}
+}
+
+// WithPortForward forwards local ports to the pod while the commands or the
+// shell run. Each port is "local:remote", ":remote" for a random local port,
+// or a single port used on both ends. It is not supported by BackendJob.
+func WithPortForward(ports ...string) Option {
+	return func(l *Launcher) {
+		l.forwards = append(l.forwards, ports...)
+	}
+}
+
+// WithClientset sets the clientset used to manage the pod. By default one is
+// built from the REST config.
+func WithClientset(clientset kubernetes.Interface) Option {
+	return func(l *Launcher) {
+		l.clientset = clientset
+	}
+}
+
+// WithKubeConfig selects the cluster and credentials the clientset and the
+// REST config are loaded with, unless set by WithClientset and WithRestConfig.
+func WithKubeConfig(config KubeConfig) Option {
+	return func(l *Launcher) {
+		l.kubeConfig = config
+	}
+}
+
+// WithRestConfig sets the REST config used to exec into the pod. By default
+// it is loaded as set by WithKubeConfig.
+func WithRestConfig(config *rest.Config) Option {
+	return func(l *Launcher) {
+		l.restConfig = config
+	}
+}
+
+// WithExecutorFactory sets the factory creating the exec streams.
+func WithExecutorFactory(factory SPDYExecutorFactory) Option {
+	return func(l *Launcher) {
+		l.executorFactory = factory
+	}
+}
+
+// WithPortForwarderFactory sets the factory creating port-forward tunnels.
+func WithPortForwarderFactory(factory PortForwarderFactory) Option {
+	return func(l *Launcher) {
+		l.portForwarderFactory = factory
+	}
+}
+
+// NewLauncher returns a Launcher with the defaults of the gopl command,
+// modified by opts.
+func NewLauncher(opts ...Option) *Launcher {
+	l := &Launcher{
+		generateName:         defaultPodNameBase,
+		namespace:            defaultNamespace,
+		containerName:        defaultContainerName,
+		errorPolicy:          FailFast,
+		outputFormat:         FormatText,
+		startupTimeout:       5 * time.Minute,
+		deleteTimeout:        time.Duration(timeout) * time.Second,
+		interruptGracePeriod: 5 * time.Second,
+		cleanup:              CleanupAlways,
+		log:                  io.Discard,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Run creates the pod, waits for it to be running, executes the commands and
+// applies the cleanup policy. The returned Result is never nil.
+//
+// Cancelling ctx aborts the wait and any running command. Unless the cleanup
+// policy is CleanupNever the pod is then deleted with the interrupt grace
+// period, bounded by the delete timeout rather than by ctx.
+//
+// With BackendJob a Job runs the commands instead; the pod is then the Job's.
+func (l *Launcher) Run(ctx context.Context) (*Result, error) {
+	if _, err := ParseOutputFormat(string(l.outputFormat)); err != nil {
+		return l.newResult(), err
+	}
+
+	var result *Result
+	var err error
+	if l.backend == BackendJob {
+		result, err = l.runJob(ctx)
+	} else {
+		result, err = l.session(ctx, ConflictAsk, l.execCommands)
+	}
+	if len(result.Commands) > 0 {
+		if writeErr := l.writeOutputFile(result); writeErr != nil && err == nil {
+			return result, writeErr
+		}
+	}
+	return result, err
+}
+
+func (l *Launcher) newResult() *Result {
+	return &Result{
+		PodName:   l.podName,
+		Namespace: l.namespace,
+		Container: l.containerName,
+		Image:     l.image,
+	}
+}
+
+// containerImage returns the image of the container commands run in.
+func (l *Launcher) containerImage(pod *corev1.Pod) string {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == l.containerName {
+			return c.Image
+		}
+	}
+	return ""
+}
+
+// session launches the pod, runs body once it is running and applies the
+// cleanup policy. A name conflict is resolved with the conflict policy, or
+// with defaultConflict when none was set. A pod that is reused is never
+// deleted. Everything after the launch refers to the pod as result.PodName.
+func (l *Launcher) session(ctx context.Context, defaultConflict ConflictPolicy,
+	body func(context.Context, v1Inter.CoreV1Interface, *Result) error) (*Result, error) {
+	result := l.newResult()
+
+	if err := l.init(); err != nil {
+		return result, &StageError{Stage: StageLaunch, Err: err}
+	}
+	coreV1 := l.clientset.CoreV1()
+
+	conflict := l.onConflict
+	if conflict == "" {
+		conflict = defaultConflict
+	}
+	created, uid, err := l.launchPod(ctx, coreV1, conflict, result)
+	if err != nil {
+		return result, &StageError{Stage: StageLaunch, Err: err}
+	}
+
+	stopLease := l.renewLease(ctx, coreV1, result.PodName)
+	var runErr *StageError
+	if err := l.waitForPodRunning(ctx, coreV1, uid, result); err != nil {
+		runErr = &StageError{Stage: StageStartup, Err: err}
+	} else if stopForward, err := l.startPortForward(ctx, coreV1, result.PodName); err != nil {
+		runErr = &StageError{Stage: StageExec, Err: err}
+	} else {
+		err := body(ctx, coreV1, result)
+		stopForward()
+		if err != nil {
+			runErr = &StageError{Stage: StageExec, Err: err}
+		}
+	}
+	stopLease()
+
+	interrupted := ctx.Err() != nil
+	if interrupted {
+		l.logf("Interrupted.\n")
+		if runErr != nil && !errors.Is(runErr, ctx.Err()) {
+			runErr.Err = fmt.Errorf("%w: %v", ctx.Err(), runErr.Err)
+		}
+	} else if l.vscodeDebug {
+		printVscodeHelp(l.log, result.PodName, l.namespace, l.containerName)
+	}
+
+	if created && l.shouldCleanup(runErr, interrupted) {
+		if err := l.cleanupPod(coreV1, result.PodName, interrupted); err != nil {
+			if runErr == nil {
+				runErr = &StageError{Stage: StageCleanup, Err: err}
+			} else {
+				runErr.Err = fmt.Errorf("%w (cleanup also failed: %v)", runErr.Err, err)
+			}
+		} else {
+			result.Deleted = true
+			l.logf("Pod deleted successfully.\n")
+		}
+	}
+
+	if runErr != nil {
+		return result, runErr
+	}
+	return result, nil
+}
+
+// launchPod creates the pod, resolving a name conflict with the given policy,
+// and records its name and image in result. It reports whether the pod was
+// created rather than reused, and the UID of a created pod.
+func (l *Launcher) launchPod(ctx context.Context, coreV1 v1Inter.CoreV1Interface, conflict ConflictPolicy,
+	result *Result) (bool, types.UID, error) {
+	cfg := l.podConfig()
+	base := l.podName
+	if base == "" {
+		// Without a name every attempt uses a generated one.
+		base, conflict = l.generateName, ConflictGenerate
+		cfg.podName = generatePodName(base)
+	}
+	now := time.Now()
+	cfg.labels, cfg.annotations = ownership(now)
+	if l.lease > 0 {
+		cfg.annotations = mergeMaps(cfg.annotations, leaseAnnotations(l.lease, now))
+	}
+
+	replaced := false
+	for attempt := 1; ; attempt++ {
+		pod, err := createPod(ctx, coreV1, cfg)
+		if err == nil {
+			result.PodName = pod.Name
+			result.Image = l.containerImage(pod)
+			l.logf("Pod created successfully. %s %s\n", pod.Name, pod.Status.Phase)
+			return true, pod.UID, nil
+		}
+		if !apierrors.IsAlreadyExists(err) {
+			return false, "", fmt.Errorf("failed to create pod %s in namespace %s: %w", cfg.podName, l.namespace, err)
+		}
+
+		switch conflict {
+		case ConflictReuse:
+			result.PodName = cfg.podName
+			l.logf("Reusing existing pod %s.\n", cfg.podName)
+			return false, "", nil
+		case ConflictGenerate:
+			if attempt < maxGenerateAttempts {
+				cfg.podName = generatePodName(base)
+				continue
+			}
+		case ConflictAsk:
+			if !term.IsTerminal(os.Stdin) ||
+				!promptAndConfirm(fmt.Sprintf("Pod %s already exists. Do you want to replace it?\n", cfg.podName)) {
+				break
+			}
+			fallthrough
+		case ConflictReplace:
+			if replaced {
+				break
+			}
+			l.logf("Replacing existing pod %s.\n", cfg.podName)
+			if err := deletePod(ctx, coreV1, l.namespace, cfg.podName, l.deleteTimeoutSeconds(), nil, l.log); err != nil {
+				return false, "", err
+			}
+			replaced = true
+			continue
+		}
+		return false, "", fmt.Errorf("failed to create pod %s in namespace %s: %w", cfg.podName, l.namespace, err)
+	}
+}
+
+// waitForPodRunning waits for the pod to start while printing its events and
+// recording them in result.
+func (l *Launcher) waitForPodRunning(ctx context.Context, coreV1 v1Inter.CoreV1Interface, uid types.UID,
+	result *Result) error {
+	events, err := watchPodEvents(ctx, coreV1, l.namespace, result.PodName, uid, l.log)
+	if err != nil {
+		l.logf("Not showing pod events: %v\n", err)
+	} else {
+		defer func() {
+			result.Events = events.stop()
+		}()
+	}
+	if err := waitForPodRunning(ctx, coreV1, l.namespace, result.PodName, l.startupTimeout); err != nil {
+		return err
+	}
+	l.logf("Pod is running.\n")
+	return nil
+}
+
+func (l *Launcher) podConfig() podConfig {
+	cfg := podConfig{
+		namespace:          l.namespace,
+		podName:            l.podName,
+		containerName:      l.containerName,
+		serviceAccountName: l.serviceAccountName,
+		nodeName:           l.nodeName,
+		image:              l.image,
+		imagePullPolicy:    l.imagePullPolicy,
+		command:            l.keepaliveCommand,
+		template:           l.podTemplate,
+	}
+	if l.ttl > 0 {
+		seconds := int64((l.ttl + time.Second - 1) / time.Second)
+		cfg.activeDeadlineSeconds = &seconds
+	}
+	return cfg
+}
+
+func (l *Launcher) shouldCleanup(runErr error, interrupted bool) bool {
+	switch l.cleanup {
+	case CleanupNever:
+		return false
+	case CleanupOnSuccess:
+		return runErr == nil || interrupted
+	}
+	return true
+}
+
+// cleanupPod deletes the pod with a context of its own, so that cleanup still
+// happens after the context of Run was cancelled.
+func (l *Launcher) cleanupPod(coreV1 v1Inter.CoreV1Interface, podName string, interrupted bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.deleteTimeout)
+	defer cancel()
+
+	var gracePeriod *int64
+	if interrupted {
+		seconds := int64(l.interruptGracePeriod / time.Second)
+		gracePeriod = &seconds
+	}
+	return deletePod(ctx, coreV1, l.namespace, podName, l.deleteTimeoutSeconds(), gracePeriod, l.log)
+}
+
+// init fills in the clients that were not supplied as options.
+func (l *Launcher) init() error {
+	if l.restConfig == nil {
+		config, err := l.kubeConfig.RestConfig()
+		if err != nil {
+			return err
+		}
+		l.restConfig = config
+	}
+	if l.clientset == nil {
+		clientset, err := kubernetes.NewForConfig(l.restConfig)
+		if err != nil {
+			return err
+		}
+		l.clientset = clientset
+	}
+	if l.executorFactory == nil {
+		l.executorFactory = &defaultSPDYExecutorFactory{}
+	}
+	if l.portForwarderFactory == nil {
+		l.portForwarderFactory = &defaultPortForwarderFactory{}
+	}
+	return nil
+}
+
+func (l *Launcher) resolveCommands() []string {
+	if l.vscodeDebug {
+		return vscode.CommandsVscode()
+	}
+	if len(l.commands) == 0 {
+		return defaultCommands
+	}
+	return l.commands
+}
+
+func (l *Launcher) deleteTimeoutSeconds() int64 {
+	return int64(l.deleteTimeout / time.Second)
+}
+
+func (l *Launcher) execCommands(ctx context.Context, coreV1 v1Inter.CoreV1Interface, result *Result) error {
+	live, closeLive, err := l.openLiveOutput()
+	if err != nil {
+		return err
+	}
+	defer closeLive()
+
+	c := &Config{restConfig: l.restConfig, log: l.log}
+	if l.script != nil {
+		result.Commands, err = c.execScriptInPod(ctx, coreV1, l.executorFactory,
+			l.namespace, result.PodName, result.Container, l.script, live)
+	} else {
+		result.Commands, err = c.execCommandsInPod(ctx, coreV1, l.executorFactory,
+			l.namespace, result.PodName, result.Container, l.resolveCommands(), l.errorPolicy, live)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to execute commands in pod %s: %w", result.PodName, err)
+	}
+	return nil
+}
+
+// openLiveOutput returns the liveOutput for the streamed output and, with the
+// text format, the output files. The returned function closes the files.
+func (l *Launcher) openLiveOutput() (*liveOutput, func(), error) {
+	live := &liveOutput{stdout: l.stdout, stderr: l.stderr, prefix: l.outputPrefix}
+	if l.outputFile == "" || l.outputFormat != FormatText {
+		return live, func() {}, nil
+	}
+	stdoutFile, err := os.Create(l.outputFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	stderrFile, err := os.Create(fmt.Sprintf("%s%s", l.outputFile, ".err"))
+	if err != nil {
+		stdoutFile.Close()
+		return nil, nil, err
+	}
+	live.fileStdout, live.fileStderr = stdoutFile, stderrFile
+	return live, func() {
+		stdoutFile.Close()
+		stderrFile.Close()
+	}, nil
+}
+
+// writeOutputFile writes result to the output file when a structured format
+// was requested. Text output has already been written by execCommands or
+// runJob.
+func (l *Launcher) writeOutputFile(result *Result) error {
+	if l.outputFile == "" {
+		return nil
+	}
+
+	if l.outputFormat != FormatText {
+		f, err := os.Create(l.outputFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := WriteResult(f, result, l.outputFormat); err != nil {
+			return err
+		}
+	}
+	l.logf("Output written to %s.\n", l.outputFile)
+	return nil
+}
+
+// logf writes a progress message to the log writer.
+func (l *Launcher) logf(format string, args ...interface{}) {
+	logf(l.log, format, args...)
+}
+
+func printVscodeHelp(w io.Writer, podName, namespace, containerName string) {
+	fmt.Fprintln(w, "vscode debug")
+	fmt.Fprintln(w, "gopl shell --podName", podName, "--namespace", namespace, "--container", containerName, "--forward 8080:8080")
+	fmt.Fprintln(w, "code-server&")
+	fmt.Fprintln(w, "cat ~/.config/code-server/config.yaml")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "http://localhost:8080")
+	fmt.Fprintln(w, "common commands:")
+	fmt.Fprintln(w, "aws configure list")
+	fmt.Fprintln(w, "aws sts get-caller-identity")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Additional installs:")
+	fmt.Fprintln(w, "yum groupinstall -y \"Development Tools\"")
+	fmt.Fprintln(w, "yum install -y python3-devel")
+	fmt.Fprintln(w, "yum install -y bind-utils")
+	fmt.Fprintln(w, "yum install -y procps lsof")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "When you're done, run the following command to delete the pod:")
+	fmt.Fprintln(w, "kubectl delete pod", podName, "-n", namespace, "--grace-period=0 --force")
+}
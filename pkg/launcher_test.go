@@ -0,0 +1,220 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+func TestNewLauncherDefaults(t *testing.T) {
+	l := NewLauncher()
+	assert.Empty(t, l.podName, "pods get generated names by default")
+	assert.Equal(t, defaultPodNameBase, l.generateName)
+	assert.Equal(t, CleanupAlways, l.cleanup)
+	assert.Equal(t, defaultCommands, l.resolveCommands())
+
+	l = NewLauncher(WithVscodeDebug(true))
+	assert.Equal(t, CleanupNever, l.cleanup, "vscode mode should leave the pod running")
+}
+
+func TestLauncherPodConfig(t *testing.T) {
+	container := buildPod(NewLauncher().podConfig()).Spec.Containers[0]
+	assert.Equal(t, defaultImage, container.Image)
+	assert.Equal(t, []string{"sleep", "3600"}, container.Command)
+	assert.Empty(t, container.ImagePullPolicy)
+
+	container = buildPod(NewLauncher(
+		WithImage("curlimages/curl:8.1.2"),
+		WithImagePullPolicy(corev1.PullIfNotPresent),
+		WithKeepaliveCommand("sleep", "infinity"),
+	).podConfig()).Spec.Containers[0]
+	assert.Equal(t, "curlimages/curl:8.1.2", container.Image)
+	assert.Equal(t, corev1.PullIfNotPresent, container.ImagePullPolicy)
+	assert.Equal(t, []string{"sleep", "infinity"}, container.Command)
+
+	_, err := ParseImagePullPolicy("Sometimes")
+	assert.Error(t, err)
+}
+
+func TestLauncherRun(t *testing.T) {
+	clientset := newRunningPodClientset()
+	var stdout, stderr bytes.Buffer
+	var log syncBuffer
+
+	l := NewLauncher(
+		WithClientset(clientset),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(&mockSPDYExecutorFactory{executor: &mockExecutor{stdout: "hello", stderr: "warn"}}),
+		WithNamespace("test-namespace"),
+		WithPodName("test-pod"),
+		WithCommands("echo hello"),
+		WithOutput(&stdout, &stderr),
+		WithLog(&log),
+	)
+	result, err := l.Run(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, result.Deleted, "pod should be deleted with the default cleanup policy")
+	assert.Equal(t, "hello\n", stdout.String())
+	assert.Equal(t, "warn\n", stderr.String())
+	assert.Contains(t, log.String(), "Pod created successfully. test-pod")
+	assert.Contains(t, log.String(), "Pod deleted successfully.")
+	assert.Len(t, result.Commands, 1)
+	assert.Equal(t, "echo hello", result.Commands[0].Command)
+
+	_, err = clientset.CoreV1().Pods("test-namespace").Get(context.Background(), "test-pod", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "pod should be gone after Run")
+}
+
+func TestLauncherRunGeneratedName(t *testing.T) {
+	clientset := newRunningPodClientset()
+
+	l := NewLauncher(
+		WithClientset(clientset),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(&mockSPDYExecutorFactory{executor: &mockExecutor{}}),
+		WithNamespace("test-namespace"),
+		WithGenerateName("test-pod"),
+		WithCommands("true"),
+		WithOutput(io.Discard, io.Discard),
+		WithCleanupPolicy(CleanupNever),
+	)
+	result, err := l.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Regexp(t, `^test-pod-[a-z0-9]{5}$`, result.PodName)
+
+	pod, err := clientset.CoreV1().Pods("test-namespace").Get(context.Background(), result.PodName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, ManagedBy, pod.Labels[LabelManagedBy])
+	assert.Contains(t, pod.Labels, LabelVersion)
+	assert.Contains(t, pod.Annotations, AnnotationHostname)
+	_, err = time.Parse(time.RFC3339, pod.Annotations[AnnotationStartedAt])
+	assert.NoError(t, err)
+}
+
+func TestLauncherRunCleanupOnSuccess(t *testing.T) {
+	clientset := newRunningPodClientset()
+
+	l := NewLauncher(
+		WithClientset(clientset),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(&mockSPDYExecutorFactory{executor: &mockExecutor{err: errors.New("boom")}}),
+		WithNamespace("test-namespace"),
+		WithPodName("test-pod"),
+		WithCleanupPolicy(CleanupOnSuccess),
+	)
+	result, err := l.Run(context.Background())
+	assert.Error(t, err)
+	assert.False(t, result.Deleted, "failed run should leave the pod behind")
+
+	_, err = clientset.CoreV1().Pods("test-namespace").Get(context.Background(), "test-pod", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+// cancelExecutor cancels the run while its command is executing.
+type cancelExecutor struct {
+	mockExecutor
+	cancel context.CancelFunc
+	// err, when set, is returned instead of the context error, like a stream
+	// torn down by the interrupt.
+	err error
+}
+
+func (e *cancelExecutor) StreamWithContext(ctx context.Context, options remotecommand.StreamOptions) error {
+	e.cancel()
+	<-ctx.Done()
+	if e.err != nil {
+		return e.err
+	}
+	return ctx.Err()
+}
+
+func TestLauncherRunInterrupted(t *testing.T) {
+	clientset := newRunningPodClientset()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l := NewLauncher(
+		WithClientset(clientset),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(&mockSPDYExecutorFactory{executor: &cancelExecutor{cancel: cancel}}),
+		WithNamespace("test-namespace"),
+		WithPodName("test-pod"),
+		WithCommands("sleep 60", "echo never"),
+		WithErrorPolicy(ContinueOnError),
+		WithCleanupPolicy(CleanupOnSuccess),
+	)
+	result, err := l.Run(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, ExitInterrupted, ExitCode(err))
+	assert.Len(t, result.Commands, 1, "no command should start after the interrupt")
+	assert.True(t, result.Deleted, "pod should be deleted after an interrupt")
+}
+
+func TestLauncherRunConflict(t *testing.T) {
+	tests := []struct {
+		policy  ConflictPolicy
+		wantErr bool
+		// existing reports whether the pre-existing pod survives the run.
+		existing bool
+		// launch is the pod name given to the launcher, test-pod by default.
+		launch string
+		// podName is the name of the launched pod, a regexp.
+		podName string
+	}{
+		{policy: ConflictReuse, existing: true},
+		{policy: ConflictReplace},
+		{policy: ConflictGenerate, existing: true, podName: `^test-pod-[a-z0-9]{5}$`},
+		{policy: ConflictGenerate, existing: true, launch: "free-pod", podName: `^free-pod$`},
+		{policy: ConflictFail, wantErr: true, existing: true},
+		{policy: ConflictAsk, wantErr: true, existing: true},
+	}
+	for _, tt := range tests {
+		if tt.launch == "" {
+			tt.launch = "test-pod"
+		}
+		t.Run(string(tt.policy)+"/"+tt.launch, func(t *testing.T) {
+			clientset := newRunningPodClientset()
+			existing, err := createPod(context.Background(), clientset.CoreV1(),
+				NewLauncher(WithNamespace("test-namespace"), WithPodName("test-pod")).podConfig())
+			assert.NoError(t, err)
+
+			l := NewLauncher(
+				WithClientset(clientset),
+				WithRestConfig(&rest.Config{}),
+				WithExecutorFactory(NewMock()),
+				WithNamespace("test-namespace"),
+				WithPodName(tt.launch),
+				WithConflictPolicy(tt.policy),
+			)
+			result, err := l.Run(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, ExitLaunchFailed, ExitCode(err))
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, result.Commands, len(defaultCommands))
+			}
+			if tt.podName != "" {
+				assert.Regexp(t, tt.podName, result.PodName)
+			}
+
+			pod, err := clientset.CoreV1().Pods("test-namespace").Get(context.Background(), "test-pod", metav1.GetOptions{})
+			if tt.existing {
+				assert.NoError(t, err)
+				assert.Equal(t, existing.UID, pod.UID)
+			} else {
+				assert.True(t, apierrors.IsNotFound(err), "replaced pod should be cleaned up")
+			}
+		})
+	}
+}
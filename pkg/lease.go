@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	v1Inter "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// A pod with a lease is renewed by gopl while its session is alive. Once the
+// lease has expired the client is gone and the pod can be reaped safely.
+const (
+	// AnnotationLeaseDuration holds how long a renewal is valid, as a Go
+	// duration such as 1m0s.
+	AnnotationLeaseDuration = "gopl.cwxstat.github.io/lease-duration"
+	// AnnotationLeaseRenewedAt holds the time of the last renewal in RFC 3339
+	// format.
+	AnnotationLeaseRenewedAt = "gopl.cwxstat.github.io/lease-renewed-at"
+)
+
+// MinLease is the shortest lease. The renewal time is stored with second
+// precision, so a shorter lease could look expired while it is renewed.
+const MinLease = 3 * time.Second
+
+func leaseAnnotations(d time.Duration, now time.Time) map[string]string {
+	return map[string]string{
+		AnnotationLeaseDuration:  d.String(),
+		AnnotationLeaseRenewedAt: now.UTC().Format(time.RFC3339),
+	}
+}
+
+// leaseExpiry returns when the lease of pod expires. It reports false when the
+// pod has no valid lease.
+func leaseExpiry(pod *corev1.Pod) (time.Time, bool) {
+	d, err := time.ParseDuration(pod.Annotations[AnnotationLeaseDuration])
+	if err != nil {
+		return time.Time{}, false
+	}
+	renewed, err := time.Parse(time.RFC3339, pod.Annotations[AnnotationLeaseRenewedAt])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return renewed.Add(d), true
+}
+
+// renewLease renews the lease of the pod every third of the lease duration
+// until the returned function is called or ctx is done. It does nothing when
+// the launcher has no lease.
+func (l *Launcher) renewLease(ctx context.Context, coreV1 v1Inter.CoreV1Interface, podName string) (stop func()) {
+	if l.lease <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(l.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				patch, _ := json.Marshal(map[string]interface{}{
+					"metadata": map[string]interface{}{"annotations": leaseAnnotations(l.lease, now)},
+				})
+				_, err := coreV1.Pods(l.namespace).Patch(ctx, podName, types.MergePatchType, patch, metav1.PatchOptions{})
+				if err != nil && ctx.Err() == nil {
+					l.logf("Failed to renew the lease of pod %s: %v\n", podName, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
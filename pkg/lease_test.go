@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestLauncherPodConfigTTL(t *testing.T) {
+	pod := buildPod(NewLauncher(WithTTL(90 * time.Minute)).podConfig())
+	if assert.NotNil(t, pod.Spec.ActiveDeadlineSeconds) {
+		assert.Equal(t, int64(5400), *pod.Spec.ActiveDeadlineSeconds)
+	}
+	assert.Equal(t, []string{"sleep", "5400"}, pod.Spec.Containers[0].Command,
+		"the default keepalive command should last as long as the pod")
+
+	pod = buildPod(NewLauncher(WithTTL(time.Hour), WithKeepaliveCommand("sleep", "infinity")).podConfig())
+	assert.Equal(t, []string{"sleep", "infinity"}, pod.Spec.Containers[0].Command)
+
+	pod = buildPod(NewLauncher().podConfig())
+	assert.Nil(t, pod.Spec.ActiveDeadlineSeconds)
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: leaseAnnotations(time.Minute, now)}}
+	expiry, ok := leaseExpiry(pod)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(time.Minute), expiry)
+
+	_, ok = leaseExpiry(&corev1.Pod{})
+	assert.False(t, ok)
+}
+
+func TestRenewLease(t *testing.T) {
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "test-pod",
+		Namespace:   "test-namespace",
+		Annotations: leaseAnnotations(time.Minute, start),
+	}}
+	clientset := fake.NewSimpleClientset(pod)
+
+	assert.Equal(t, MinLease, NewLauncher(WithLease(time.Nanosecond)).lease)
+
+	l := NewLauncher(WithNamespace("test-namespace"))
+	// Shorter than MinLease, to renew quickly.
+	l.lease = 30 * time.Millisecond
+	stop := l.renewLease(ctx, clientset.CoreV1(), "test-pod")
+	assert.Eventually(t, func() bool {
+		pod, err := clientset.CoreV1().Pods("test-namespace").Get(ctx, "test-pod", metav1.GetOptions{})
+		if err != nil {
+			return false
+		}
+		expiry, ok := leaseExpiry(pod)
+		return ok && expiry.After(start.Add(time.Hour))
+	}, time.Second, 10*time.Millisecond)
+	stop()
+}
+
+func TestLauncherGCKeepsLiveLeases(t *testing.T) {
+	ctx := context.Background()
+	live := gcPod("default", "live", "alice", 2*time.Hour, corev1.PodRunning, true)
+	live.Annotations = mergeMaps(live.Annotations, leaseAnnotations(time.Minute, time.Now()))
+	expired := gcPod("default", "expired", "alice", 2*time.Hour, corev1.PodRunning, true)
+	expired.Annotations = mergeMaps(expired.Annotations, leaseAnnotations(time.Minute, time.Now().Add(-time.Hour)))
+	clientset := fake.NewSimpleClientset(live, expired)
+
+	l := NewLauncher(WithClientset(clientset), WithRestConfig(&rest.Config{}))
+	pods, err := l.GC(ctx, GCOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"default/expired"}, podNames(pods))
+}
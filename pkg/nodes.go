@@ -0,0 +1,47 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RunNodes runs the whole lifecycle of Run on each node matching the label
+// selector, or on every node when it is empty. Each node gets its own pod,
+// pinned to it with nodeName and named after it. The nodes run concurrently,
+// at most parallelism at a time; zero runs them all at once. Output is handled
+// as by RunContexts.
+func (l *Launcher) RunNodes(ctx context.Context, selector string, parallelism int) (*MultiResult, error) {
+	if _, err := ParseOutputFormat(string(l.outputFormat)); err != nil {
+		return nil, err
+	}
+	if err := l.init(); err != nil {
+		return nil, &StageError{Stage: StageLaunch, Err: err}
+	}
+	nodes, err := l.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, &StageError{Stage: StageLaunch, Err: fmt.Errorf("failed to list nodes: %w", err)}
+	}
+	if len(nodes.Items) == 0 {
+		return nil, &StageError{Stage: StageLaunch, Err: fmt.Errorf("no nodes match selector %q", selector)}
+	}
+	names := make([]string, 0, len(nodes.Items))
+	for _, node := range nodes.Items {
+		names = append(names, node.Name)
+	}
+
+	base := l.podName
+	if base == "" {
+		base = l.generateName
+	}
+	multi := &MultiResult{
+		Nodes: fanOut(ctx, names, parallelism, func(name string) *Launcher {
+			c := l.forTarget(name)
+			c.nodeName = name
+			c.podName, c.generateName = "", base+"-"+name
+			return c
+		}),
+	}
+	return multi, l.report(multi)
+}
@@ -0,0 +1,59 @@
+package pkg
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestLauncherRunNodes(t *testing.T) {
+	ctx := context.Background()
+	clientset := newRunningPodClientset()
+	for name, role := range map[string]string{"node-a": "worker", "node-b": "worker", "node-c": "control-plane"} {
+		_, err := clientset.CoreV1().Nodes().Create(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"role": role},
+		}}, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	var log syncBuffer
+	l := NewLauncher(
+		WithClientset(clientset),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(&mockSPDYExecutorFactory{executor: &mockExecutor{stdout: "hello"}}),
+		WithNamespace("test-namespace"),
+		WithCommands("echo hello"),
+		WithOutputFile(""),
+		WithCleanupPolicy(CleanupNever),
+		WithLog(&log),
+	)
+	multi, err := l.RunNodes(ctx, "role=worker", 0)
+	require.NoError(t, err)
+	assert.Contains(t, log.String(), "[node-a] Pod created successfully.")
+	assert.Contains(t, log.String(), "[node-b] Pod is running.")
+	assert.Contains(t, log.String(), "NODE", "the summary table goes to the log writer")
+	assert.Nil(t, multi.Contexts)
+	require.Len(t, multi.Nodes, 2)
+
+	pods, err := clientset.CoreV1().Pods("test-namespace").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, pods.Items, 2)
+	for _, pod := range pods.Items {
+		tr := multi.Nodes[pod.Spec.NodeName]
+		if assert.NotNil(t, tr, "pod %s should run on a selected node", pod.Name) {
+			assert.Equal(t, pod.Name, tr.Result.PodName)
+			assert.True(t, strings.HasPrefix(pod.Name, "aws-cli-pod-"+pod.Spec.NodeName+"-"), pod.Name)
+			assert.Len(t, tr.Result.Commands, 1)
+		}
+	}
+
+	_, err = l.RunNodes(ctx, "role=gpu", 0)
+	assert.Equal(t, ExitLaunchFailed, ExitCode(err))
+}
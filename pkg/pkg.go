@@ -5,24 +5,20 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"github.com/cwxstat/go-pod-launch-run/pkg/vscode"
-	"github.com/emicklei/go-restful/v3/log"
+	"io"
 	"k8s.io/api/core/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/watch"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	v1Inter "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
-	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/client-go/tools/remotecommand"
-	blog "log"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -48,190 +44,127 @@ func do() {
 	B(&defaultSPDYExecutorFactory{})
 }
 
-func Run(podName,
-	namespace,
-	containerName,
-	serviceAccountName string,
-	vscodeDebug bool,
-	commands []string, output string) error {
+// podConfig describes the pod created by createPod. Empty fields are taken
+// from the template, if any, and otherwise from the gopl defaults.
+type podConfig struct {
+	namespace          string
+	podName            string
+	containerName      string
+	serviceAccountName string
+	nodeName           string
+	image              string
+	imagePullPolicy    v1.PullPolicy
+	// command keeps the container alive so that commands can be executed in
+	// it.
+	command  []string
+	template *v1.PodTemplateSpec
+	// activeDeadlineSeconds bounds the lifetime of the pod. The default
+	// keepalive command then sleeps as long.
+	activeDeadlineSeconds *int64
+	// labels and annotations are added to those of the template.
+	labels      map[string]string
+	annotations map[string]string
+}
 
-	clientset, err := getClientset()
-	if err != nil {
-		panic(err)
+// buildPod merges cfg into its template. The container named
+// cfg.containerName is taken from the template or added to it.
+func buildPod(cfg podConfig) *v1.Pod {
+	template := &v1.PodTemplateSpec{}
+	if cfg.template != nil {
+		template = cfg.template.DeepCopy()
 	}
 
-	if vscodeDebug {
-		log.Printf("vscodeDebug: %v", vscodeDebug)
-		commands = vscode.CommandsVscode()
-	} else if len(commands) == 0 {
-		commands = []string{"aws configure list", "aws sts get-caller-identity"}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        cfg.podName,
+			Namespace:   cfg.namespace,
+			Labels:      mergeMaps(template.Labels, cfg.labels),
+			Annotations: mergeMaps(template.Annotations, cfg.annotations),
+		},
+		Spec: template.Spec,
 	}
-
-	//podName := "aws-cli-pod"
-	//namespace := "default"
-	//containerName := "aws-cli"
-
-	// Launch the Pod
-	pod, err := createPod(clientset.CoreV1(), namespace, podName, containerName, serviceAccountName)
-	if err != nil {
-		//fmt.Println("Failed to create Pod: ", err.Error())
-		if strings.Contains(err.Error(), "already exists") {
-			if promptAndConfirm(fmt.Sprintf("Pod %s already exists. Do you want to delete it?\n", podName)) {
-				err = deletePod(clientset.CoreV1(), namespace, podName)
-				return err
-			}
-			return err
-		}
-		return err
+	if cfg.serviceAccountName != "" {
+		pod.Spec.ServiceAccountName = cfg.serviceAccountName
 	}
-	fmt.Println("Pod created successfully.", pod.Status.Phase)
-
-	var wg sync.WaitGroup
-	wg.Add(1)
-
-	rc, err := NewRestConfig()
-
-	// Run commands in separate goroutine
-	go func() {
-		defer wg.Done()
-
-		// Wait for Pod to be running
-		err = waitForPodRunning(clientset.CoreV1(), namespace, podName)
-		if err != nil {
-			panic(err)
-		}
-
-		// Execute the commands and write the output to a file
-
-		err = rc.execCommandsInPod(clientset.CoreV1(),
-			&defaultSPDYExecutorFactory{},
-			namespace, podName, containerName, commands, output)
-		if err != nil {
-			log.Printf("Failed to execute commands in Pod: %v", err)
-		} else {
-			fmt.Println("Commands executed successfully. Output written to result.pod.")
-		}
-
-	}()
-
-	wg.Wait()
-
-	if vscodeDebug {
-		fmt.Println("vscode debug")
-		fmt.Println("kubectl exec -it", podName, "-n", namespace, "--container", containerName, "--", "bash")
-		fmt.Println("kubectl port-forward", podName, "8080:8080", "-n", namespace)
-		fmt.Println("code-server&")
-		fmt.Println("cat ~/.config/code-server/config.yaml")
-		fmt.Println("")
-		fmt.Println("http://localhost:8080")
-		fmt.Println("common commands:")
-		fmt.Println("aws configure list")
-		fmt.Println("aws sts get-caller-identity")
-		fmt.Println("")
-		fmt.Println("Additional installs:")
-		fmt.Println("yum groupinstall -y \"Development Tools\"")
-		fmt.Println("yum install -y python3-devel")
-		fmt.Println("yum install -y bind-utils")
-		fmt.Println("yum install -y procps lsof")
-		fmt.Println("")
-		fmt.Println("When you're done, run the following command to delete the pod:")
-		fmt.Println("kubectl delete pod", podName, "-n", namespace, "--grace-period=0 --force")
-		return nil
+	if cfg.nodeName != "" {
+		pod.Spec.NodeName = cfg.nodeName
 	}
-
-	// Delete the Pod
-	err = deletePod(clientset.CoreV1(), namespace, podName)
-	if err != nil {
-		panic(err)
+	if pod.Spec.RestartPolicy == "" {
+		pod.Spec.RestartPolicy = v1.RestartPolicyNever
+	}
+	if cfg.activeDeadlineSeconds != nil {
+		pod.Spec.ActiveDeadlineSeconds = cfg.activeDeadlineSeconds
 	}
 
-	fmt.Println("Pod deleted successfully.")
-
-	return nil
-}
-
-func getClientset() (*kubernetes.Clientset, error) {
-	var config *rest.Config
-	var err error
-	// Check if the program is running inside a Kubernetes cluster.
-	if _, err = rest.InClusterConfig(); err != nil {
-		kubeconfig := os.Getenv("KUBECONFIG")
-		if kubeconfig == "" {
-			kubeconfig = clientcmd.RecommendedHomeFile
-		}
-
-		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-			&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
-			&clientcmd.ConfigOverrides{ClusterInfo: clientcmdapi.Cluster{Server: ""}}).ClientConfig()
-		if err != nil {
-			blog.Fatalf("Failed to load Kubernetes configuration: %v", err)
-		}
-	} else {
-		config, err = rest.InClusterConfig()
-		if err != nil {
-			blog.Fatalf("Failed to load in-cluster configuration: %v", err)
+	var container *v1.Container
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == cfg.containerName {
+			container = &pod.Spec.Containers[i]
 		}
 	}
+	if container == nil {
+		pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{Name: cfg.containerName})
+		container = &pod.Spec.Containers[len(pod.Spec.Containers)-1]
+	}
+	switch {
+	case cfg.image != "":
+		container.Image = cfg.image
+	case container.Image == "":
+		container.Image = defaultImage
+	}
+	if cfg.imagePullPolicy != "" {
+		container.ImagePullPolicy = cfg.imagePullPolicy
+	}
+	switch {
+	case len(cfg.command) > 0:
+		container.Command = cfg.command
+	case len(container.Command) == 0 && pod.Spec.ActiveDeadlineSeconds != nil:
+		container.Command = []string{"sleep", strconv.FormatInt(*pod.Spec.ActiveDeadlineSeconds, 10)}
+	case len(container.Command) == 0:
+		container.Command = defaultKeepaliveCommand
+	}
 
-	return kubernetes.NewForConfig(config)
-
+	return pod
 }
 
-func createPod(clientsetCoreV1 v1Inter.CoreV1Interface, namespace, podName, containerName,
-	serviceAccountName string) (*v1.Pod,
-	error) {
-	pod := &v1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      podName,
-			Namespace: namespace,
-		},
-		Spec: v1.PodSpec{
-			ServiceAccountName: serviceAccountName,
-			Containers: []v1.Container{
-				{
-					Name:    containerName,
-					Image:   "amazon/aws-cli:latest",
-					Command: []string{"sleep", "3600"},
-				},
-			},
-			RestartPolicy: v1.RestartPolicyNever,
-		},
+// mergeMaps returns the entries of base overridden by those of overrides, or
+// nil when both are empty.
+func mergeMaps(base, overrides map[string]string) map[string]string {
+	if len(base) == 0 && len(overrides) == 0 {
+		return nil
 	}
-
-	return clientsetCoreV1.Pods(namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+	merged := make(map[string]string, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
 }
 
-func waitForPodRunning(clientsetCoreV1 v1Inter.CoreV1Interface, namespace, podName string) error {
-	for {
-		pod, err := clientsetCoreV1.Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
-		if err != nil {
-			return err
-		}
-
-		if pod.Status.Phase == v1.PodRunning {
-			fmt.Println("Pod is running.")
-			break
-		} else if pod.Status.Phase == v1.PodFailed || pod.Status.Phase == v1.PodSucceeded {
-			return fmt.Errorf("pod %s in namespace %s failed to start, current status: %v", podName, namespace, pod.Status.Phase)
-		}
-
-		time.Sleep(1 * time.Second)
-	}
-	return nil
+func createPod(ctx context.Context, clientsetCoreV1 v1Inter.CoreV1Interface, cfg podConfig) (*v1.Pod,
+	error) {
+	return clientsetCoreV1.Pods(cfg.namespace).Create(ctx, buildPod(cfg), metav1.CreateOptions{})
 }
 
-func deletePod(clientsetCoreV1 v1Inter.CoreV1Interface, namespace, podName string) error {
+// deletePod deletes the pod and waits up to timeout seconds for it to be gone.
+// A nil gracePeriodSeconds keeps the pod's own termination grace period.
+// Progress is written to log, which may be nil.
+func deletePod(ctx context.Context, clientsetCoreV1 v1Inter.CoreV1Interface, namespace, podName string,
+	timeout int64, gracePeriodSeconds *int64, log io.Writer) error {
 	deletePolicy := metav1.DeletePropagationForeground
 	deleteOptions := metav1.DeleteOptions{
-		PropagationPolicy: &deletePolicy,
+		PropagationPolicy:  &deletePolicy,
+		GracePeriodSeconds: gracePeriodSeconds,
 	}
 
-	err := clientsetCoreV1.Pods(namespace).Delete(context.Background(), podName, deleteOptions)
+	err := clientsetCoreV1.Pods(namespace).Delete(ctx, podName, deleteOptions)
 	if err != nil {
 		return fmt.Errorf("failed to delete pod %s in namespace %s: %v", podName, namespace, err)
 	}
 
-	err = waitForPodDeletion(clientsetCoreV1, namespace, podName, &timeout)
+	err = waitForPodDeletion(ctx, clientsetCoreV1, namespace, podName, &timeout, log)
 	if err != nil {
 		return fmt.Errorf("failed to wait for pod %s in namespace %s to be deleted: %v", podName, namespace, err)
 	}
@@ -239,21 +172,28 @@ func deletePod(clientsetCoreV1 v1Inter.CoreV1Interface, namespace, podName strin
 	return nil
 }
 
-func waitForPodDeletion(clientsetCoreV1 v1Inter.CoreV1Interface, namespace, podName string, timeout *int64) error {
+func waitForPodDeletion(ctx context.Context, clientsetCoreV1 v1Inter.CoreV1Interface, namespace, podName string, timeout *int64,
+	log io.Writer) error {
 
 	var watchOptions = metav1.ListOptions{
 		FieldSelector:  fmt.Sprintf("metadata.name=%s", podName),
 		TimeoutSeconds: timeout,
 		Watch:          true,
 	}
-	watcher, err := clientsetCoreV1.Pods(namespace).Watch(context.Background(), watchOptions)
+	watcher, err := clientsetCoreV1.Pods(namespace).Watch(ctx, watchOptions)
 	if err != nil {
 		return err
 	}
 
 	defer watcher.Stop()
 
-	fmt.Println("Waiting for pod deletion...")
+	// The pod may already be gone before the watch was established.
+	_, err = clientsetCoreV1.Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	logf(log, "Waiting for pod deletion...\n")
 	ch := watcher.ResultChan()
 	for {
 		select {
@@ -270,86 +210,111 @@ func waitForPodDeletion(clientsetCoreV1 v1Inter.CoreV1Interface, namespace, podN
 			}
 		case <-time.After(time.Duration(*timeout) * time.Second):
 			return fmt.Errorf("timeout waiting for pod deletion")
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
 }
 
 type Config struct {
 	restConfig *rest.Config
+	// log receives progress messages. It may be nil.
+	log io.Writer
 }
 
+// NewRestConfig loads the REST config from the kubeconfig, falling back to
+// the in-cluster config. Use KubeConfig to select another context or user.
 func NewRestConfig() (*Config, error) {
-	// Note: You need result config to be able to connect to the cluster from outside
-	kubeconfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		clientcmd.NewDefaultClientConfigLoadingRules(),
-		&clientcmd.ConfigOverrides{},
-	)
-	restconfig, err := kubeconfig.ClientConfig()
+	restConfig, err := KubeConfig{}.RestConfig()
 	if err != nil {
 		return nil, err
 	}
-
 	return &Config{
-		restConfig: restconfig,
+		restConfig: restConfig,
 	}, nil
 }
 
-func (c *Config) execCommandsInPod(clientsetCoreV1 v1Inter.CoreV1Interface,
+// execCommandsInPod runs each command with /bin/sh -c in the container and
+// records a CommandResult for it, copying the output to live while the command
+// runs. The policy decides whether the remaining
+// commands still run after a failure; none run once ctx is done. When any
+// command failed the results are returned together with a *CommandsFailedError.
+func (c *Config) execCommandsInPod(ctx context.Context, clientsetCoreV1 v1Inter.CoreV1Interface,
 	icmd SPDYExecutorFactory,
 	namespace,
 	podName,
 	containerName string,
-	commands []string, outputFile string) error {
-	var outputBuffer bytes.Buffer
-	var outputErrorBuffer bytes.Buffer
+	commands []string, policy ErrorPolicy, live *liveOutput) ([]CommandResult, error) {
+	results := make([]CommandResult, 0, len(commands))
+	failedErr := &CommandsFailedError{Total: len(commands)}
 
-	fmt.Println("Executing commands in pod... wait for it...")
+	logf(c.log, "Executing commands in pod... wait for it...\n")
 	for _, cmd := range commands {
-		req := clientsetCoreV1.RESTClient().Post().
-			Resource("pods").
-			Name(podName).
-			Namespace(namespace).
-			SubResource("exec").
-			VersionedParams(&corev1.PodExecOptions{
-				Container: containerName,
-				Command:   []string{"/bin/sh", "-c", cmd},
-				Stdout:    true,
-				Stderr:    true,
-			}, scheme.ParameterCodec)
-
-		//executor, err := remotecommand.NewSPDYExecutor(c.restConfig, "POST", req.URL())
-		executor, err := icmd.NewSPDYExecutor(c.restConfig, "POST", req.URL())
-		if err != nil {
-			return err
+		if policy.stop(failedErr.Failed) || ctx.Err() != nil {
+			break
 		}
 
+		execURL := execRequestURL(clientsetCoreV1, namespace, podName, &corev1.PodExecOptions{
+			Container: containerName,
+			Command:   []string{"/bin/sh", "-c", cmd},
+			Stdout:    true,
+			Stderr:    true,
+		})
+
 		var cmdOutputBuffer bytes.Buffer
 		var cmdStderrBuffer bytes.Buffer
-		err = executor.Stream(remotecommand.StreamOptions{
-			Stdout: &cmdOutputBuffer,
-			Stderr: &cmdStderrBuffer,
-			Tty:    false,
-		})
+		liveStdout, liveStderr := live.start(cmd)
+		start := time.Now()
 
-		if err != nil {
-			fmt.Errorf("failed to execute command %s: %v", cmd, err)
-			return err
+		//executor, err := remotecommand.NewSPDYExecutor(c.restConfig, "POST", req.URL())
+		executor, err := icmd.NewSPDYExecutor(c.restConfig, "POST", execURL)
+		if err == nil {
+			err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+				Stdout: io.MultiWriter(&cmdOutputBuffer, liveStdout),
+				Stderr: io.MultiWriter(&cmdStderrBuffer, liveStderr),
+				Tty:    false,
+			})
+		}
+		if finishErr := live.finish(); finishErr != nil && err == nil {
+			err = finishErr
 		}
 
-		outputBuffer.Write(cmdOutputBuffer.Bytes())
-		outputBuffer.WriteString("\n")
-
-		outputErrorBuffer.Write(cmdStderrBuffer.Bytes())
-		outputErrorBuffer.WriteString("\n")
+		result := newCommandResult(cmd, cmdOutputBuffer.Bytes(), cmdStderrBuffer.Bytes(), start, err)
+		results = append(results, result)
+		if !result.Succeeded() {
+			failedErr.Failed++
+			failedErr.LastExitCode = result.ExitCode
+			if result.Error != "" {
+				failedErr.ExecErrors++
+			}
+			logf(c.log, "Command %q failed: %v\n", cmd, err)
+		}
+	}
 
+	if failedErr.Failed > 0 {
+		failedErr.Run = len(results)
+		return results, failedErr
 	}
+	return results, ctx.Err()
+}
 
-	err := os.WriteFile(outputFile, outputBuffer.Bytes(), 0644)
-	if err != nil {
-		return err
+// execRequestURL returns the URL of the exec subresource of the pod.
+func execRequestURL(clientsetCoreV1 v1Inter.CoreV1Interface, namespace, podName string,
+	options *corev1.PodExecOptions) *url.URL {
+	return clientsetCoreV1.RESTClient().Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource("exec").
+		VersionedParams(options, scheme.ParameterCodec).
+		URL()
+}
+
+// logf writes a progress message to w, unless w is nil.
+func logf(w io.Writer, format string, args ...interface{}) {
+	if w != nil {
+		fmt.Fprintf(w, format, args...)
 	}
-	err = os.WriteFile(fmt.Sprintf("%s%s", outputFile, ".err"), outputErrorBuffer.Bytes(), 0644)
-	return err
 }
 
 func promptAndConfirm(prompt string) bool {
@@ -357,7 +322,7 @@ func promptAndConfirm(prompt string) bool {
 	reader := bufio.NewReader(os.Stdin)
 	input, err := reader.ReadString('\n')
 	if err != nil {
-		os.Exit(1)
+		return false
 	}
 	if strings.ToLower(strings.TrimSpace(input)) == "y" {
 		return true
@@ -4,14 +4,19 @@ import (
 	"context"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
 	"k8s.io/client-go/util/flowcontrol"
 	"net/url"
 	"testing"
@@ -29,7 +34,14 @@ func TestCreatePod(t *testing.T) {
 	serviceAccountName := "test-service-account"
 
 	// Test createPod function
-	createdPod, err := createPod(clientset.CoreV1(), namespace, podName, containerName, serviceAccountName)
+	createdPod, err := createPod(context.Background(), clientset.CoreV1(), podConfig{
+		namespace:          namespace,
+		podName:            podName,
+		containerName:      containerName,
+		serviceAccountName: serviceAccountName,
+		image:              defaultImage,
+		command:            defaultKeepaliveCommand,
+	})
 	if err == nil {
 		t.Logf("Created pod: %v\n", createdPod.Name)
 		t.Logf("  namespace: %v\n", createdPod.Namespace)
@@ -82,7 +94,7 @@ func TestWaitForPodDeletion(t *testing.T) {
 		assert.NoError(t, err)
 	}()
 
-	err = waitForPodRunning(clientset.CoreV1(), namespace, podName)
+	err = waitForPodRunning(context.Background(), clientset.CoreV1(), namespace, podName, 0)
 	assert.NoError(t, err, "waitForPodRunning should not return an error if the pod is running within the timeout")
 
 	// Delete the pod asynchronously after 1 second
@@ -93,7 +105,7 @@ func TestWaitForPodDeletion(t *testing.T) {
 	}()
 
 	// Test waitForPodDeletion function
-	err = waitForPodDeletion(clientset.CoreV1(), namespace, podName, &timeout)
+	err = waitForPodDeletion(context.Background(), clientset.CoreV1(), namespace, podName, &timeout, nil)
 	assert.NoError(t, err, "waitForPodDeletion should not return an error if the pod is deleted within the timeout")
 
 	// Create a new pod
@@ -110,7 +122,7 @@ func TestWaitForPodDeletion(t *testing.T) {
 	shortTimeout := int64(1)
 
 	// Test waitForPodDeletion function with shorter timeout
-	err = waitForPodDeletion(clientset.CoreV1(), namespace, podName, &shortTimeout)
+	err = waitForPodDeletion(context.Background(), clientset.CoreV1(), namespace, podName, &shortTimeout, nil)
 	assert.Error(t, err, "waitForPodDeletion should return an error if the pod is not deleted within the timeout")
 }
 
@@ -131,14 +143,22 @@ func (f *mockSPDYExecutorFactory) NewSPDYExecutor(config *rest.Config, method st
 }
 
 type mockExecutor struct {
-	err error
+	stdout string
+	stderr string
+	err    error
 }
 
 func (m *mockExecutor) Stream(options remotecommand.StreamOptions) error {
-	return nil
+	if options.Stdout != nil {
+		io.WriteString(options.Stdout, m.stdout)
+	}
+	if options.Stderr != nil {
+		io.WriteString(options.Stderr, m.stderr)
+	}
+	return m.err
 }
 func (m *mockExecutor) StreamWithContext(ctx context.Context, options remotecommand.StreamOptions) error {
-	return nil
+	return m.Stream(options)
 }
 
 type mRestClient struct{}
@@ -173,6 +193,45 @@ type customFakeCoreV1 struct {
 	v1.CoreV1Interface
 }
 
+// RESTClient returns a client that is never dialed; it is only used to build
+// the exec request URL handed to the mock executor factory.
+func (c *customFakeCoreV1) RESTClient() rest.Interface {
+	restClient, err := rest.RESTClientFor(&rest.Config{
+		Host:    "https://localhost",
+		APIPath: "/api",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &corev1.SchemeGroupVersion,
+			NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return restClient
+}
+
+// customFakeClientset serves customFakeCoreV1 so that exec requests can be
+// built against a fake clientset.
+type customFakeClientset struct {
+	*fake.Clientset
+}
+
+func (c *customFakeClientset) CoreV1() v1.CoreV1Interface {
+	return &customFakeCoreV1{CoreV1Interface: c.Clientset.CoreV1()}
+}
+
+// newRunningPodClientset returns a fake clientset whose pods are running as
+// soon as they are created.
+func newRunningPodClientset() *customFakeClientset {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		pod.Status.Phase = corev1.PodRunning
+		return false, nil, nil
+	})
+	return &customFakeClientset{Clientset: clientset}
+}
+
 //func TestExecCommandsInPod(t *testing.T) {
 //	// Set up a fake clientset for simulating a Kubernetes cluster
 //	clientset := fake.NewSimpleClientset()
@@ -209,3 +268,58 @@ type customFakeCoreV1 struct {
 //	err = os.Remove(outputFile)
 //	assert.NoError(t, err)
 //}
+
+// sequenceExecutorFactory hands out its executors in order, one per command.
+type sequenceExecutorFactory struct {
+	executors []remotecommand.Executor
+	calls     int
+}
+
+func (f *sequenceExecutorFactory) NewSPDYExecutor(config *rest.Config, method string, url *url.URL) (remotecommand.Executor, error) {
+	executor := f.executors[f.calls]
+	f.calls++
+	return executor, nil
+}
+
+func TestExecCommandsInPodErrorPolicy(t *testing.T) {
+	exitErr := utilexec.CodeExitError{Err: fmt.Errorf("command terminated with exit code 2"), Code: 2}
+	newFactory := func() *sequenceExecutorFactory {
+		return &sequenceExecutorFactory{executors: []remotecommand.Executor{
+			&mockExecutor{stdout: "one"},
+			&mockExecutor{err: exitErr},
+			&mockExecutor{err: exitErr},
+			&mockExecutor{stdout: "four"},
+		}}
+	}
+	commands := []string{"one", "two", "three", "four"}
+	coreV1 := &customFakeCoreV1{CoreV1Interface: fake.NewSimpleClientset().CoreV1()}
+	c := &Config{restConfig: &rest.Config{}}
+
+	tests := []struct {
+		name    string
+		policy  ErrorPolicy
+		results int
+	}{
+		{name: "fail fast", policy: FailFast, results: 2},
+		{name: "continue", policy: ContinueOnError, results: 4},
+		{name: "stop after two", policy: StopAfter(2), results: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := c.execCommandsInPod(context.Background(), coreV1, newFactory(), "ns", "pod", "container", commands, tt.policy, nil)
+			assert.Len(t, results, tt.results)
+			assert.Equal(t, 2, results[1].ExitCode)
+
+			var failedErr *CommandsFailedError
+			assert.ErrorAs(t, err, &failedErr)
+			assert.Equal(t, tt.results, failedErr.Run)
+			assert.Equal(t, len(commands), failedErr.Total)
+		})
+	}
+
+	results, err := c.execCommandsInPod(context.Background(), coreV1, &sequenceExecutorFactory{executors: []remotecommand.Executor{
+		&mockExecutor{stdout: "one"},
+	}}, "ns", "pod", "container", commands[:1], FailFast, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "one", results[0].Stdout)
+}
@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	v1Inter "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// PortForwarder forwards local ports to a pod until its stop channel is
+// closed. *portforward.PortForwarder implements it.
+type PortForwarder interface {
+	ForwardPorts() error
+	GetPorts() ([]portforward.ForwardedPort, error)
+}
+
+// PortForwarderFactory creates the PortForwarder for the portforward URL of a
+// pod. Ports use the "local:remote" syntax of kubectl port-forward.
+type PortForwarderFactory interface {
+	NewPortForwarder(config *rest.Config, url *url.URL, ports []string,
+		stopChan <-chan struct{}, readyChan chan struct{}) (PortForwarder, error)
+}
+
+type defaultPortForwarderFactory struct{}
+
+func (f *defaultPortForwarderFactory) NewPortForwarder(config *rest.Config, url *url.URL, ports []string,
+	stopChan <-chan struct{}, readyChan chan struct{}) (PortForwarder, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(config)
+	if err != nil {
+		return nil, err
+	}
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", url)
+	return portforward.NewOnAddresses(dialer, []string{"localhost"}, ports, stopChan, readyChan, io.Discard, io.Discard)
+}
+
+// startPortForward forwards the configured ports to the pod and returns once
+// the tunnel is ready. The returned function tears it down.
+func (l *Launcher) startPortForward(ctx context.Context, coreV1 v1Inter.CoreV1Interface, podName string) (func(), error) {
+	if len(l.forwards) == 0 {
+		return func() {}, nil
+	}
+
+	forwardURL := coreV1.RESTClient().Post().
+		Resource("pods").
+		Namespace(l.namespace).
+		Name(podName).
+		SubResource("portforward").
+		URL()
+
+	stopChan := make(chan struct{})
+	readyChan := make(chan struct{})
+	forwarder, err := l.portForwarderFactory.NewPortForwarder(l.restConfig, forwardURL, l.forwards, stopChan, readyChan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up port-forward to pod %s: %w", podName, err)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- forwarder.ForwardPorts()
+	}()
+	stop := func() {
+		close(stopChan)
+		<-errChan
+		l.logf("Port-forward stopped.\n")
+	}
+
+	select {
+	case <-readyChan:
+	case err := <-errChan:
+		return nil, fmt.Errorf("failed to port-forward to pod %s: %w", podName, err)
+	case <-ctx.Done():
+		close(stopChan)
+		return nil, ctx.Err()
+	}
+
+	ports, err := forwarder.GetPorts()
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	for _, port := range ports {
+		l.logf("Forwarding localhost:%d -> %s:%d\n", port.Local, podName, port.Remote)
+	}
+	return stop, nil
+}
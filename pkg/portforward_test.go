@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+)
+
+type mockPortForwarder struct {
+	stopChan  <-chan struct{}
+	readyChan chan struct{}
+	err       error
+	stopped   bool
+}
+
+func (f *mockPortForwarder) ForwardPorts() error {
+	if f.err != nil {
+		return f.err
+	}
+	close(f.readyChan)
+	<-f.stopChan
+	f.stopped = true
+	return nil
+}
+
+func (f *mockPortForwarder) GetPorts() ([]portforward.ForwardedPort, error) {
+	return []portforward.ForwardedPort{{Local: 8080, Remote: 8080}}, nil
+}
+
+type mockPortForwarderFactory struct {
+	forwarder *mockPortForwarder
+	url       *url.URL
+	ports     []string
+}
+
+func (f *mockPortForwarderFactory) NewPortForwarder(config *rest.Config, url *url.URL, ports []string,
+	stopChan <-chan struct{}, readyChan chan struct{}) (PortForwarder, error) {
+	f.url, f.ports = url, ports
+	f.forwarder.stopChan, f.forwarder.readyChan = stopChan, readyChan
+	return f.forwarder, nil
+}
+
+func TestLauncherRunPortForward(t *testing.T) {
+	factory := &mockPortForwarderFactory{forwarder: &mockPortForwarder{}}
+	l := NewLauncher(
+		WithClientset(newRunningPodClientset()),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(NewMock()),
+		WithPortForwarderFactory(factory),
+		WithPortForward("8080:8080"),
+		WithNamespace("test-namespace"),
+		WithPodName("test-pod"),
+	)
+	_, err := l.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"8080:8080"}, factory.ports)
+	assert.Equal(t, "/api/v1/namespaces/test-namespace/pods/test-pod/portforward", factory.url.Path)
+	assert.True(t, factory.forwarder.stopped, "port-forward should be torn down when the run ends")
+}
+
+func TestLauncherRunPortForwardFailure(t *testing.T) {
+	factory := &mockPortForwarderFactory{forwarder: &mockPortForwarder{err: errors.New("address in use")}}
+	l := NewLauncher(
+		WithClientset(newRunningPodClientset()),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(NewMock()),
+		WithPortForwarderFactory(factory),
+		WithPortForward("8080:8080"),
+	)
+	result, err := l.Run(context.Background())
+	assert.ErrorContains(t, err, "address in use")
+	assert.Empty(t, result.Commands, "commands should not run without the tunnel")
+	assert.True(t, result.Deleted)
+}
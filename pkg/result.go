@@ -0,0 +1,185 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilexec "k8s.io/client-go/util/exec"
+	"sigs.k8s.io/yaml"
+)
+
+// OutputFormat selects how a Result is written to the output file.
+type OutputFormat string
+
+const (
+	// FormatText writes the concatenated stdout to the output file and the
+	// concatenated stderr to the output file with an .err suffix.
+	FormatText OutputFormat = "text"
+	// FormatJSON writes the Result as JSON.
+	FormatJSON OutputFormat = "json"
+	// FormatYAML writes the Result as YAML.
+	FormatYAML OutputFormat = "yaml"
+)
+
+// ParseOutputFormat validates s as an OutputFormat.
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch f := OutputFormat(s); f {
+	case FormatText, FormatJSON, FormatYAML:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q, must be one of %s, %s or %s", s, FormatText, FormatJSON, FormatYAML)
+}
+
+// Result describes a finished Run.
+type Result struct {
+	PodName string `json:"podName"`
+	// Job is the name of the Job that ran the commands with BackendJob.
+	Job       string `json:"job,omitempty"`
+	Namespace string `json:"namespace"`
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	// Deleted reports whether the pod was deleted by Run.
+	Deleted bool `json:"deleted"`
+	// Events are the Kubernetes events about the pod seen while it started.
+	Events   []PodEvent      `json:"events,omitempty"`
+	Commands []CommandResult `json:"commands"`
+}
+
+// CommandResult is the outcome of a single command executed in the pod.
+type CommandResult struct {
+	Command string `json:"command"`
+	Stdout  string `json:"stdout"`
+	Stderr  string `json:"stderr"`
+	// ExitCode is the exit status reported by the container, or -1 when the
+	// command could not be run at all.
+	ExitCode int `json:"exitCode"`
+	// Error describes why the command could not be run. It is empty when the
+	// command ran, whatever its exit code.
+	Error     string          `json:"error,omitempty"`
+	StartTime metav1.Time     `json:"startTime"`
+	EndTime   metav1.Time     `json:"endTime"`
+	Duration  metav1.Duration `json:"duration"`
+}
+
+// Succeeded reports whether the command ran and exited with status 0.
+func (r CommandResult) Succeeded() bool {
+	return r.Error == "" && r.ExitCode == 0
+}
+
+// newCommandResult records the outcome of a command from the error returned
+// by the exec stream. A non-zero exit status is not treated as an error.
+func newCommandResult(cmd string, stdout, stderr []byte, start time.Time, err error) CommandResult {
+	end := time.Now()
+	r := CommandResult{
+		Command:   cmd,
+		Stdout:    string(stdout),
+		Stderr:    string(stderr),
+		StartTime: metav1.NewTime(start),
+		EndTime:   metav1.NewTime(end),
+		Duration:  metav1.Duration{Duration: end.Sub(start)},
+	}
+	var exitErr utilexec.CodeExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		r.ExitCode = exitErr.Code
+	default:
+		r.ExitCode = -1
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// ErrorPolicy decides whether a batch of commands keeps going after some of
+// them failed. A command fails when it cannot be run or exits non-zero.
+type ErrorPolicy struct {
+	// MaxFailures is the number of failed commands after which the remaining
+	// commands are skipped. Zero runs every command.
+	MaxFailures int
+}
+
+var (
+	// FailFast skips the remaining commands after the first failure.
+	FailFast = ErrorPolicy{MaxFailures: 1}
+	// ContinueOnError runs every command regardless of failures.
+	ContinueOnError = ErrorPolicy{}
+)
+
+// StopAfter skips the remaining commands once n commands have failed.
+func StopAfter(n int) ErrorPolicy {
+	return ErrorPolicy{MaxFailures: n}
+}
+
+// ParseErrorPolicy parses "fail-fast" or "continue".
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	switch s {
+	case "fail-fast":
+		return FailFast, nil
+	case "continue":
+		return ContinueOnError, nil
+	}
+	return ErrorPolicy{}, fmt.Errorf("unknown error policy %q, must be fail-fast or continue", s)
+}
+
+func (p ErrorPolicy) stop(failed int) bool {
+	return p.MaxFailures > 0 && failed >= p.MaxFailures
+}
+
+// CommandsFailedError reports that some commands of a batch failed.
+type CommandsFailedError struct {
+	// Failed is the number of commands that failed.
+	Failed int
+	// Run is the number of commands that were attempted.
+	Run int
+	// Total is the number of commands in the batch.
+	Total int
+	// ExecErrors is the number of commands that could not be run at all.
+	ExecErrors int
+	// LastExitCode is the exit code of the last failed command.
+	LastExitCode int
+}
+
+func (e *CommandsFailedError) Error() string {
+	if e.Run < e.Total {
+		return fmt.Sprintf("%d of %d commands failed, %d skipped", e.Failed, e.Run, e.Total-e.Run)
+	}
+	return fmt.Sprintf("%d of %d commands failed", e.Failed, e.Run)
+}
+
+// WriteResult writes result to w in the given format. The text format writes
+// the stdout of each command, newline separated.
+func WriteResult(w io.Writer, result *Result, format OutputFormat) error {
+	var out []byte
+	var err error
+	switch format {
+	case FormatText:
+		out, _ = joinOutput(result.Commands)
+	case FormatJSON:
+		out, err = json.MarshalIndent(result, "", "  ")
+		out = append(out, '\n')
+	case FormatYAML:
+		out, err = yaml.Marshal(result)
+	default:
+		_, err = ParseOutputFormat(string(format))
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// joinOutput concatenates stdout and stderr of all commands, each followed by
+// a newline.
+func joinOutput(results []CommandResult) ([]byte, []byte) {
+	var stdout, stderr []byte
+	for _, r := range results {
+		stdout = append(append(stdout, r.Stdout...), '\n')
+		stderr = append(append(stderr, r.Stderr...), '\n')
+	}
+	return stdout, stderr
+}
@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	utilexec "k8s.io/client-go/util/exec"
+	"sigs.k8s.io/yaml"
+)
+
+func TestNewCommandResult(t *testing.T) {
+	start := time.Now()
+
+	r := newCommandResult("true", []byte("out"), []byte("err"), start, nil)
+	assert.Equal(t, 0, r.ExitCode)
+	assert.True(t, r.Succeeded())
+	assert.Equal(t, "out", r.Stdout)
+	assert.Equal(t, "err", r.Stderr)
+	assert.False(t, r.EndTime.Before(&r.StartTime))
+
+	r = newCommandResult("exit 3", nil, nil, start, utilexec.CodeExitError{Err: errors.New("exit 3"), Code: 3})
+	assert.Equal(t, 3, r.ExitCode)
+	assert.Empty(t, r.Error, "a non-zero exit status is not an exec error")
+	assert.False(t, r.Succeeded())
+
+	r = newCommandResult("ls", nil, nil, start, errors.New("connection refused"))
+	assert.Equal(t, -1, r.ExitCode)
+	assert.Equal(t, "connection refused", r.Error)
+}
+
+func TestWriteResult(t *testing.T) {
+	result := &Result{
+		PodName:   "test-pod",
+		Namespace: "test-namespace",
+		Commands: []CommandResult{
+			{Command: "echo a", Stdout: "a"},
+			{Command: "echo b", Stdout: "b", ExitCode: 1},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteResult(&buf, result, FormatText))
+	assert.Equal(t, "a\nb\n", buf.String())
+
+	for _, format := range []OutputFormat{FormatJSON, FormatYAML} {
+		buf.Reset()
+		assert.NoError(t, WriteResult(&buf, result, format))
+
+		data := buf.Bytes()
+		if format == FormatYAML {
+			var err error
+			data, err = yaml.YAMLToJSON(data)
+			assert.NoError(t, err)
+		}
+		var decoded Result
+		assert.NoError(t, json.Unmarshal(data, &decoded), "format %s", format)
+		assert.Equal(t, result.PodName, decoded.PodName)
+		assert.Len(t, decoded.Commands, 2)
+		assert.Equal(t, 1, decoded.Commands[1].ExitCode)
+	}
+
+	assert.Error(t, WriteResult(&buf, result, "xml"))
+}
@@ -0,0 +1,104 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1Inter "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// Script is a local script run in the pod by WithScript.
+type Script struct {
+	// Name identifies the script in the CommandResult, usually its file name.
+	Name string
+	// Data is the content of the script.
+	Data []byte
+	// Interpreter runs the script, for example bash or python3 -u. Empty
+	// executes the script itself, which then needs a #! line.
+	Interpreter []string
+}
+
+// LoadScript reads a script for WithScript from a local file.
+func LoadScript(path string, interpreter ...string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{Name: filepath.Base(path), Data: data, Interpreter: interpreter}, nil
+}
+
+// command returns the command recorded in the CommandResult of the script.
+func (s *Script) command() string {
+	return strings.Join(append(append([]string{}, s.Interpreter...), s.Name), " ")
+}
+
+// shellCommand returns the shell command that saves the script read from stdin
+// to a temporary file, makes it executable, runs it and removes it, exiting
+// with the status of the script.
+func (s *Script) shellCommand() string {
+	run := `"$f"`
+	if len(s.Interpreter) > 0 {
+		quoted := make([]string, len(s.Interpreter))
+		for i, arg := range s.Interpreter {
+			quoted[i] = shellQuote(arg)
+		}
+		run = strings.Join(quoted, " ") + ` "$f"`
+	}
+	return `f=$(mktemp 2>/dev/null || echo /tmp/gopl-script-$$) && cat > "$f" && chmod +x "$f" || exit 126
+` + run + ` </dev/null
+rc=$?
+rm -f "$f"
+exit $rc`
+}
+
+// execScriptInPod streams the script to the container over the exec stdin and
+// runs it, recording a single CommandResult. The output is copied to live
+// while the script runs.
+func (c *Config) execScriptInPod(ctx context.Context, clientsetCoreV1 v1Inter.CoreV1Interface,
+	icmd SPDYExecutorFactory, namespace, podName, containerName string,
+	script *Script, live *liveOutput) ([]CommandResult, error) {
+	execURL := execRequestURL(clientsetCoreV1, namespace, podName, &corev1.PodExecOptions{
+		Container: containerName,
+		Command:   []string{"/bin/sh", "-c", script.shellCommand()},
+		Stdin:     true,
+		Stdout:    true,
+		Stderr:    true,
+	})
+
+	var stdout, stderr bytes.Buffer
+	name := script.command()
+	liveStdout, liveStderr := live.start(name)
+	start := time.Now()
+
+	logf(c.log, "Running script %s in pod...\n", script.Name)
+	executor, err := icmd.NewSPDYExecutor(c.restConfig, "POST", execURL)
+	if err == nil {
+		err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+			Stdin:  bytes.NewReader(script.Data),
+			Stdout: io.MultiWriter(&stdout, liveStdout),
+			Stderr: io.MultiWriter(&stderr, liveStderr),
+		})
+	}
+	if finishErr := live.finish(); finishErr != nil && err == nil {
+		err = finishErr
+	}
+
+	result := newCommandResult(name, stdout.Bytes(), stderr.Bytes(), start, err)
+	results := []CommandResult{result}
+	if !result.Succeeded() {
+		failedErr := &CommandsFailedError{Failed: 1, Run: 1, Total: 1, LastExitCode: result.ExitCode}
+		if result.Error != "" {
+			failedErr.ExecErrors = 1
+		}
+		logf(c.log, "Script %s failed: %v\n", script.Name, err)
+		return results, failedErr
+	}
+	return results, ctx.Err()
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+func TestScriptShellCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell to run the script")
+	}
+	run := func(script *Script) (string, int) {
+		cmd := exec.Command("sh", "-c", script.shellCommand())
+		cmd.Stdin = bytes.NewReader(script.Data)
+		out, err := cmd.Output()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return string(out), exitErr.ExitCode()
+		}
+		require.NoError(t, err)
+		return string(out), 0
+	}
+
+	out, code := run(&Script{Name: "state.sh", Data: []byte("#!/bin/sh\nx=kept\ncd /\necho \"$x $(pwd)\"\nexit 5\n")})
+	assert.Equal(t, "kept /\n", out, "shell state carries across lines")
+	assert.Equal(t, 5, code)
+
+	out, code = run(&Script{Name: "plain.sh", Data: []byte("echo no shebang needed\n"), Interpreter: []string{"sh"}})
+	assert.Equal(t, "no shebang needed\n", out)
+	assert.Equal(t, 0, code)
+}
+
+// recordingExecutor records the exec stdin and the commands it was created
+// for.
+type recordingExecutor struct {
+	urls  []*url.URL
+	stdin bytes.Buffer
+	exec  mockExecutor
+}
+
+func (r *recordingExecutor) NewSPDYExecutor(config *rest.Config, method string, u *url.URL) (remotecommand.Executor, error) {
+	r.urls = append(r.urls, u)
+	return r, nil
+}
+
+func (r *recordingExecutor) Stream(options remotecommand.StreamOptions) error {
+	return r.StreamWithContext(context.Background(), options)
+}
+
+func (r *recordingExecutor) StreamWithContext(ctx context.Context, options remotecommand.StreamOptions) error {
+	if options.Stdin != nil {
+		io.Copy(&r.stdin, options.Stdin)
+	}
+	return r.exec.Stream(options)
+}
+
+func TestLauncherRunScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "check.py")
+	require.NoError(t, os.WriteFile(path, []byte("print('hello')\n"), 0o600))
+	script, err := LoadScript(path, "python3", "-u")
+	require.NoError(t, err)
+
+	executor := &recordingExecutor{exec: mockExecutor{stdout: "hello\n"}}
+	l := NewLauncher(
+		WithClientset(newRunningPodClientset()),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(executor),
+		WithNamespace("test-namespace"),
+		WithCommands("ignored"),
+		WithScript(script),
+		WithOutputFile(""),
+		WithOutput(io.Discard, io.Discard),
+	)
+	result, err := l.Run(context.Background())
+	require.NoError(t, err)
+	require.Len(t, result.Commands, 1)
+	assert.Equal(t, "python3 -u check.py", result.Commands[0].Command)
+	assert.Equal(t, "hello\n", result.Commands[0].Stdout)
+	assert.Equal(t, "print('hello')\n", executor.stdin.String())
+
+	require.Len(t, executor.urls, 1)
+	query := executor.urls[0].Query()
+	assert.Equal(t, "true", query.Get("stdin"))
+	assert.Contains(t, strings.Join(query["command"], " "), `'python3' '-u' "$f"`)
+
+	executor.exec.err = utilexec.CodeExitError{Err: assert.AnError, Code: 2}
+	_, err = l.Run(context.Background())
+	assert.Equal(t, 2, ExitCode(err), "the script's exit status is gopl's")
+}
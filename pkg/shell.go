@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/cwxstat/go-pod-launch-run/pkg/term"
+	corev1 "k8s.io/api/core/v1"
+	v1Inter "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// defaultShell starts bash when the image has it and sh otherwise.
+var defaultShell = []string{"/bin/sh", "-c", "command -v bash >/dev/null && exec bash || exec sh"}
+
+// ShellOptions describes the interactive session opened by Launcher.Shell.
+type ShellOptions struct {
+	// Command is run instead of the default shell when set.
+	Command []string
+	Stdin   io.Reader
+	Stdout  io.Writer
+	Stderr  io.Writer
+}
+
+// Shell launches the pod, or by default reuses it when it already exists, and
+// attaches an interactive session to it. When Stdin and Stdout are a terminal, the
+// session gets a TTY: the local terminal is put into raw mode and window
+// resizes are forwarded. The cleanup policy applies only to a pod created by
+// Shell. A non-zero exit of the shell is reported like a failed command.
+func (l *Launcher) Shell(ctx context.Context, opts ShellOptions) (*Result, error) {
+	return l.session(ctx, ConflictReuse, func(ctx context.Context, coreV1 v1Inter.CoreV1Interface, result *Result) error {
+		command := opts.Command
+		if len(command) == 0 {
+			command = defaultShell
+		}
+
+		start := time.Now()
+		c := &Config{restConfig: l.restConfig}
+		err := c.execShellInPod(ctx, coreV1, l.executorFactory, l.namespace, result.PodName, l.containerName, command, opts)
+
+		cmdResult := newCommandResult(strings.Join(command, " "), nil, nil, start, err)
+		result.Commands = append(result.Commands, cmdResult)
+		if !cmdResult.Succeeded() {
+			failedErr := &CommandsFailedError{Failed: 1, Run: 1, Total: 1, LastExitCode: cmdResult.ExitCode}
+			if cmdResult.Error != "" {
+				failedErr.ExecErrors = 1
+				return fmt.Errorf("%w: %v", failedErr, err)
+			}
+			return failedErr
+		}
+		return nil
+	})
+}
+
+func (c *Config) execShellInPod(ctx context.Context, clientsetCoreV1 v1Inter.CoreV1Interface,
+	icmd SPDYExecutorFactory,
+	namespace,
+	podName,
+	containerName string,
+	command []string, opts ShellOptions) error {
+	t := term.Terminal{In: opts.Stdin, Out: opts.Stdout}
+	tty := t.IsTerminal()
+
+	execURL := execRequestURL(clientsetCoreV1, namespace, podName, &corev1.PodExecOptions{
+		Container: containerName,
+		Command:   command,
+		Stdin:     opts.Stdin != nil,
+		Stdout:    opts.Stdout != nil,
+		Stderr:    opts.Stderr != nil && !tty,
+		TTY:       tty,
+	})
+	executor, err := icmd.NewSPDYExecutor(c.restConfig, "POST", execURL)
+	if err != nil {
+		return err
+	}
+
+	streamOptions := remotecommand.StreamOptions{
+		Stdin:  opts.Stdin,
+		Stdout: opts.Stdout,
+		Tty:    tty,
+	}
+	if tty {
+		restore, err := t.MakeRaw()
+		if err != nil {
+			return err
+		}
+		defer restore()
+
+		sizeQueue := t.MonitorSize()
+		defer sizeQueue.Stop()
+		streamOptions.TerminalSizeQueue = sizeQueue
+	} else {
+		streamOptions.Stderr = opts.Stderr
+	}
+
+	return executor.StreamWithContext(ctx, streamOptions)
+}
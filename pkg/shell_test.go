@@ -0,0 +1,38 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+func TestLauncherShellReusesPod(t *testing.T) {
+	clientset := newRunningPodClientset()
+	_, err := createPod(context.Background(), clientset.CoreV1(),
+		NewLauncher(WithNamespace("test-namespace"), WithPodName("test-pod")).podConfig())
+	assert.NoError(t, err)
+
+	exitErr := utilexec.CodeExitError{Err: assert.AnError, Code: 7}
+	l := NewLauncher(
+		WithClientset(clientset),
+		WithRestConfig(&rest.Config{}),
+		WithExecutorFactory(&mockSPDYExecutorFactory{executor: &mockExecutor{stdout: "$ ", err: exitErr}}),
+		WithNamespace("test-namespace"),
+		WithPodName("test-pod"),
+	)
+	var stdout bytes.Buffer
+	result, err := l.Shell(context.Background(), ShellOptions{Stdin: strings.NewReader("exit 7\n"), Stdout: &stdout})
+	assert.Error(t, err)
+	assert.Equal(t, 7, ExitCode(err), "shell exit code should be propagated")
+	assert.Equal(t, "$ ", stdout.String())
+	assert.False(t, result.Deleted, "a reused pod should not be deleted")
+
+	_, err = clientset.CoreV1().Pods("test-namespace").Get(context.Background(), "test-pod", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,109 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// liveOutput copies the output of each command while it runs to the
+// terminal writers, optionally prefixed with the command, and to the text
+// output files. Any of the writers may be nil, as may a *liveOutput.
+type liveOutput struct {
+	stdout     io.Writer
+	stderr     io.Writer
+	prefix     bool
+	fileStdout io.Writer
+	fileStderr io.Writer
+
+	current []*lineWriter
+}
+
+// start returns the writers receiving the stdout and stderr of cmd.
+func (o *liveOutput) start(cmd string) (io.Writer, io.Writer) {
+	if o == nil {
+		return io.Discard, io.Discard
+	}
+	prefix := ""
+	if o.prefix {
+		prefix = fmt.Sprintf("[%s] ", cmd)
+	}
+	o.current = o.current[:0]
+	return o.tee(o.stdout, o.fileStdout, prefix), o.tee(o.stderr, o.fileStderr, prefix)
+}
+
+func (o *liveOutput) tee(terminal, file io.Writer, prefix string) io.Writer {
+	var writers []io.Writer
+	if terminal != nil {
+		lw := &lineWriter{w: terminal, prefix: []byte(prefix), atLineStart: true}
+		o.current = append(o.current, lw)
+		writers = append(writers, lw)
+	}
+	if file != nil {
+		writers = append(writers, file)
+	}
+	return io.MultiWriter(writers...)
+}
+
+// finish terminates the output of the current command: the terminal output
+// ends on a new line and the output files get their command separator.
+func (o *liveOutput) finish() error {
+	if o == nil {
+		return nil
+	}
+	for _, lw := range o.current {
+		if err := lw.endLine(); err != nil {
+			return err
+		}
+	}
+	for _, f := range []io.Writer{o.fileStdout, o.fileStderr} {
+		if f == nil {
+			continue
+		}
+		if _, err := io.WriteString(f, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineWriter writes prefix at the start of every line.
+type lineWriter struct {
+	w           io.Writer
+	prefix      []byte
+	atLineStart bool
+	written     bool
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if lw.atLineStart && len(lw.prefix) > 0 {
+			if _, err := lw.w.Write(lw.prefix); err != nil {
+				return 0, err
+			}
+		}
+		line := p
+		i := bytes.IndexByte(p, '\n')
+		if i >= 0 {
+			line = p[:i+1]
+		}
+		if _, err := lw.w.Write(line); err != nil {
+			return 0, err
+		}
+		lw.atLineStart = i >= 0
+		lw.written = true
+		p = p[len(line):]
+	}
+	return n, nil
+}
+
+// endLine terminates a trailing partial line.
+func (lw *lineWriter) endLine() error {
+	if !lw.written || lw.atLineStart {
+		return nil
+	}
+	lw.atLineStart = true
+	_, err := lw.w.Write([]byte("\n"))
+	return err
+}
@@ -0,0 +1,37 @@
+package pkg
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveOutput(t *testing.T) {
+	var terminal, file bytes.Buffer
+	live := &liveOutput{stdout: &terminal, prefix: true, fileStdout: &file}
+
+	stdout, stderr := live.start("ls")
+	io.WriteString(stdout, "a\nb")
+	io.WriteString(stdout, "c\nd\n")
+	io.WriteString(stderr, "ignored")
+	assert.NoError(t, live.finish())
+
+	stdout, _ = live.start("pwd")
+	io.WriteString(stdout, "/root")
+	assert.NoError(t, live.finish())
+
+	assert.Equal(t, "[ls] a\n[ls] bc\n[ls] d\n[pwd] /root\n", terminal.String())
+	assert.Equal(t, "a\nbc\nd\n\n/root\n", file.String(), "files keep the unprefixed text format")
+}
+
+func TestLiveOutputNil(t *testing.T) {
+	var live *liveOutput
+	stdout, stderr := live.start("ls")
+	_, err := io.WriteString(stdout, "a")
+	assert.NoError(t, err)
+	_, err = io.WriteString(stderr, "b")
+	assert.NoError(t, err)
+	assert.NoError(t, live.finish())
+}
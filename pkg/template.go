@@ -0,0 +1,50 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadPodTemplate reads a pod template for WithPodTemplate from a YAML or
+// JSON file, see DecodePodTemplate.
+func LoadPodTemplate(path string) (*corev1.PodTemplateSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	template, err := DecodePodTemplate(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pod template %s: %w", path, err)
+	}
+	return template, nil
+}
+
+// DecodePodTemplate decodes a Pod or a PodTemplate manifest, or a bare
+// PodTemplateSpec without apiVersion and kind.
+func DecodePodTemplate(data []byte) (*corev1.PodTemplateSpec, error) {
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if runtime.IsMissingKind(err) {
+		template := &corev1.PodTemplateSpec{}
+		if err := yaml.UnmarshalStrict(data, template); err != nil {
+			return nil, err
+		}
+		return template, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		return &corev1.PodTemplateSpec{ObjectMeta: o.ObjectMeta, Spec: o.Spec}, nil
+	case *corev1.PodTemplate:
+		return &o.Template, nil
+	}
+	return nil, fmt.Errorf("unsupported kind %s, expected Pod or PodTemplate",
+		obj.GetObjectKind().GroupVersionKind().Kind)
+}
@@ -0,0 +1,104 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const podManifest = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: ignored
+  labels:
+    team: platform
+spec:
+  serviceAccountName: irsa-debug
+  nodeSelector:
+    kubernetes.io/os: linux
+  tolerations:
+  - key: dedicated
+    operator: Exists
+  containers:
+  - name: aws-cli
+    image: amazon/aws-cli:2.11.0
+    resources:
+      limits:
+        memory: 256Mi
+  - name: sidecar
+    image: busybox
+`
+
+func TestDecodePodTemplate(t *testing.T) {
+	template, err := DecodePodTemplate([]byte(podManifest))
+	assert.NoError(t, err)
+	assert.Equal(t, "platform", template.Labels["team"])
+	assert.Len(t, template.Spec.Containers, 2)
+
+	template, err = DecodePodTemplate([]byte(`
+apiVersion: v1
+kind: PodTemplate
+metadata:
+  name: debug
+template:
+  spec:
+    nodeName: node-1
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, "node-1", template.Spec.NodeName)
+
+	template, err = DecodePodTemplate([]byte(`
+spec:
+  priorityClassName: low
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, "low", template.Spec.PriorityClassName)
+
+	_, err = DecodePodTemplate([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`))
+	assert.Error(t, err)
+}
+
+func TestBuildPodFromTemplate(t *testing.T) {
+	template, err := DecodePodTemplate([]byte(podManifest))
+	assert.NoError(t, err)
+
+	pod := buildPod(podConfig{
+		namespace:     "tools",
+		podName:       "debug-pod",
+		containerName: "aws-cli",
+		command:       []string{"sleep", "600"},
+		template:      template,
+	})
+	assert.Equal(t, "debug-pod", pod.Name)
+	assert.Equal(t, "tools", pod.Namespace)
+	assert.Equal(t, "platform", pod.Labels["team"])
+	assert.Equal(t, "irsa-debug", pod.Spec.ServiceAccountName, "template service account is kept")
+	assert.Equal(t, corev1.RestartPolicyNever, pod.Spec.RestartPolicy)
+	assert.Len(t, pod.Spec.Tolerations, 1)
+	assert.Len(t, pod.Spec.Containers, 2)
+
+	container := pod.Spec.Containers[0]
+	assert.Equal(t, "amazon/aws-cli:2.11.0", container.Image, "template image is kept")
+	assert.Equal(t, []string{"sleep", "600"}, container.Command)
+	assert.Equal(t, "256Mi", container.Resources.Limits.Memory().String())
+
+	pod = buildPod(podConfig{
+		podName:            "debug-pod",
+		containerName:      "toolbox",
+		serviceAccountName: "other",
+		image:              "curlimages/curl",
+		template:           template,
+	})
+	assert.Equal(t, "other", pod.Spec.ServiceAccountName)
+	assert.Len(t, pod.Spec.Containers, 3, "a missing container is added")
+	assert.Equal(t, "curlimages/curl", pod.Spec.Containers[2].Image)
+	assert.Equal(t, defaultKeepaliveCommand, pod.Spec.Containers[2].Command)
+	assert.Len(t, template.Spec.Containers, 2, "the template is not modified")
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package term
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// resizeEvents signals on every SIGWINCH until done is closed.
+func resizeEvents(done <-chan struct{}) <-chan struct{} {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+
+	events := make(chan struct{}, 1)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-done:
+				return
+			case <-signals:
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return events
+}
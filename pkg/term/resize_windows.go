@@ -0,0 +1,9 @@
+//go:build windows
+
+package term
+
+// resizeEvents never fires on Windows, which has no SIGWINCH; the initial
+// size is still reported.
+func resizeEvents(done <-chan struct{}) <-chan struct{} {
+	return make(chan struct{})
+}
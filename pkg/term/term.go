@@ -0,0 +1,119 @@
+package term
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/term"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// Terminal is an interactive terminal reading from In and writing to Out.
+type Terminal struct {
+	In  io.Reader
+	Out io.Writer
+}
+
+// IsTerminal reports whether both In and Out are attached to a terminal.
+func (t Terminal) IsTerminal() bool {
+	return IsTerminal(t.In) && IsTerminal(t.Out)
+}
+
+// IsTerminal reports whether v is a file attached to a terminal.
+func IsTerminal(v interface{}) bool {
+	f, ok := fd(v)
+	return ok && term.IsTerminal(f)
+}
+
+// MakeRaw puts In into raw mode so that keystrokes, including Ctrl-C, are
+// passed on unprocessed. The returned function restores the previous state.
+func (t Terminal) MakeRaw() (func() error, error) {
+	in, ok := fd(t.In)
+	if !ok {
+		return func() error { return nil }, nil
+	}
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return nil, err
+	}
+	return func() error { return term.Restore(in, state) }, nil
+}
+
+// Size returns the current size of Out, or nil when it is unknown.
+func (t Terminal) Size() *remotecommand.TerminalSize {
+	out, ok := fd(t.Out)
+	if !ok {
+		return nil
+	}
+	width, height, err := term.GetSize(out)
+	if err != nil {
+		return nil
+	}
+	return &remotecommand.TerminalSize{Width: uint16(width), Height: uint16(height)}
+}
+
+// SizeQueue reports the size of Out, first immediately and then whenever
+// the window is resized, until stop is called.
+type SizeQueue struct {
+	sizes chan remotecommand.TerminalSize
+	done  chan struct{}
+}
+
+var _ remotecommand.TerminalSizeQueue = &SizeQueue{}
+
+// MonitorSize starts watching Out for window resizes.
+func (t Terminal) MonitorSize() *SizeQueue {
+	q := &SizeQueue{
+		sizes: make(chan remotecommand.TerminalSize, 1),
+		done:  make(chan struct{}),
+	}
+	if size := t.Size(); size != nil {
+		q.sizes <- *size
+	}
+	go q.watch(t, resizeEvents(q.done))
+	return q
+}
+
+func (q *SizeQueue) watch(t Terminal, resized <-chan struct{}) {
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-resized:
+		}
+		size := t.Size()
+		if size == nil {
+			continue
+		}
+		// Keep only the latest size if the previous one was not consumed.
+		select {
+		case <-q.sizes:
+		default:
+		}
+		q.sizes <- *size
+	}
+}
+
+// Next blocks until the terminal size changes. It returns nil once the queue
+// is stopped.
+func (q *SizeQueue) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-q.sizes:
+		return &size
+	case <-q.done:
+		return nil
+	}
+}
+
+// Stop ends monitoring.
+func (q *SizeQueue) Stop() {
+	close(q.done)
+}
+
+func fd(v interface{}) (int, bool) {
+	f, ok := v.(*os.File)
+	if !ok {
+		return 0, false
+	}
+	return int(f.Fd()), true
+}
@@ -0,0 +1,275 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	v1Inter "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// StartFailure classifies why a pod did not start.
+type StartFailure string
+
+const (
+	// StartImagePull means the container image could not be pulled.
+	StartImagePull StartFailure = "ImagePull"
+	// StartUnschedulable means no node could take the pod, for example
+	// because of resource requests, quota or node selectors.
+	StartUnschedulable StartFailure = "Unschedulable"
+	// StartCrashed means the container could not be created or kept
+	// exiting.
+	StartCrashed StartFailure = "Crashed"
+	// StartPodTerminated means the pod reached the Failed or Succeeded phase
+	// without running the commands.
+	StartPodTerminated StartFailure = "PodTerminated"
+	// StartTimeout means the startup timeout expired without a more specific
+	// diagnosis.
+	StartTimeout StartFailure = "Timeout"
+)
+
+// PodStartError is returned when the launched pod does not reach the Running
+// phase.
+type PodStartError struct {
+	PodName   string
+	Namespace string
+	Failure   StartFailure
+	// Reason is the Kubernetes reason, such as ImagePullBackOff.
+	Reason  string
+	Message string
+	// TimedOut is set when the startup timeout expired; Failure then holds
+	// the last known problem, if any.
+	TimedOut bool
+}
+
+func (e *PodStartError) Error() string {
+	msg := fmt.Sprintf("pod %s in namespace %s did not start", e.PodName, e.Namespace)
+	if e.TimedOut {
+		msg += " before the startup timeout"
+	}
+	if e.Failure != StartTimeout {
+		msg += fmt.Sprintf(": %s", e.Failure)
+	}
+	if e.Reason != "" {
+		msg += fmt.Sprintf(" (%s)", e.Reason)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// fatal reports whether waiting longer cannot help.
+func (e *PodStartError) fatal() bool {
+	return e.Failure != StartUnschedulable && e.Failure != StartTimeout
+}
+
+// Container waiting reasons after which the container will not start on its
+// own.
+var (
+	imagePullReasons = map[string]bool{
+		"ErrImagePull":      true,
+		"ImagePullBackOff":  true,
+		"InvalidImageName":  true,
+		"ErrImageNeverPull": true,
+	}
+	crashReasons = map[string]bool{
+		"CrashLoopBackOff":           true,
+		"CreateContainerConfigError": true,
+		"CreateContainerError":       true,
+		"RunContainerError":          true,
+	}
+)
+
+// diagnosePod reports whether the pod is running and otherwise what keeps it
+// from running, if anything is known.
+func diagnosePod(pod *corev1.Pod) (bool, *PodStartError) {
+	newErr := func(failure StartFailure, reason, message string) *PodStartError {
+		return &PodStartError{PodName: pod.Name, Namespace: pod.Namespace,
+			Failure: failure, Reason: reason, Message: message}
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodRunning:
+		return true, nil
+	case corev1.PodFailed, corev1.PodSucceeded:
+		reason, message := pod.Status.Reason, pod.Status.Message
+		for _, cs := range pod.Status.ContainerStatuses {
+			if t := cs.State.Terminated; t != nil && reason == "" {
+				reason, message = t.Reason, fmt.Sprintf("container %s exited with code %d", cs.Name, t.ExitCode)
+			}
+		}
+		if reason == "" {
+			reason = string(pod.Status.Phase)
+		}
+		return false, newErr(StartPodTerminated, reason, message)
+	}
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		waiting := cs.State.Waiting
+		if waiting == nil {
+			continue
+		}
+		switch {
+		case imagePullReasons[waiting.Reason]:
+			return false, newErr(StartImagePull, waiting.Reason, waiting.Message)
+		case crashReasons[waiting.Reason]:
+			return false, newErr(StartCrashed, waiting.Reason, waiting.Message)
+		}
+	}
+
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse &&
+			cond.Reason == corev1.PodReasonUnschedulable {
+			return false, newErr(StartUnschedulable, cond.Reason, cond.Message)
+		}
+	}
+	return false, nil
+}
+
+// waitForPodRunning watches the pod until it is running. It fails as soon as
+// the pod cannot start, and with the last known problem once startupTimeout
+// expires. A zero startupTimeout waits until ctx is done.
+func waitForPodRunning(ctx context.Context, clientsetCoreV1 v1Inter.CoreV1Interface, namespace, podName string,
+	startupTimeout time.Duration) error {
+	var timeoutCh <-chan time.Time
+	if startupTimeout > 0 {
+		timer := time.NewTimer(startupTimeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	lastIssue := &PodStartError{PodName: podName, Namespace: namespace, Failure: StartTimeout}
+	check := func(pod *corev1.Pod) (bool, error) {
+		running, issue := diagnosePod(pod)
+		switch {
+		case running:
+			return true, nil
+		case issue == nil:
+			return false, nil
+		case issue.fatal():
+			return true, issue
+		}
+		lastIssue = issue
+		return false, nil
+	}
+
+	err := watchPods(ctx, clientsetCoreV1.Pods(namespace), podNameOptions(podName),
+		getPod(ctx, clientsetCoreV1, namespace, podName), &timeoutCh, check)
+	if err == errWaitTimeout {
+		lastIssue.TimedOut = true
+		return lastIssue
+	}
+	return err
+}
+
+var errWaitTimeout = errors.New("timeout")
+
+// podNameOptions selects the pod named podName.
+func podNameOptions(podName string) metav1.ListOptions {
+	return metav1.ListOptions{FieldSelector: fmt.Sprintf("metadata.name=%s", podName)}
+}
+
+// getPod returns the list function of watchPods getting a single pod.
+func getPod(ctx context.Context, clientsetCoreV1 v1Inter.CoreV1Interface, namespace, podName string) func() ([]corev1.Pod, error) {
+	return func() ([]corev1.Pod, error) {
+		pod, err := clientsetCoreV1.Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return []corev1.Pod{*pod}, nil
+	}
+}
+
+// watchPods feeds the pods selected by options to check until it is done:
+// first the pods returned by list, then every change to them. The watch starts
+// before list is called so that no change is missed, and starts again when it
+// expires on the server side. The deletion of a selected pod ends the wait
+// with an error. When *timeoutCh fires first watchPods returns errWaitTimeout;
+// check may stop the timeout by setting *timeoutCh to nil.
+func watchPods(ctx context.Context, pods v1Inter.PodInterface, options metav1.ListOptions,
+	list func() ([]corev1.Pod, error), timeoutCh *<-chan time.Time, check func(*corev1.Pod) (bool, error)) error {
+	selected, err := podSelector(options)
+	if err != nil {
+		return err
+	}
+	for {
+		watcher, err := pods.Watch(ctx, options)
+		if err != nil {
+			return err
+		}
+		done, err := func() (bool, error) {
+			defer watcher.Stop()
+			current, err := list()
+			if err != nil {
+				return true, err
+			}
+			for i := range current {
+				if !selected(&current[i]) {
+					continue
+				}
+				if done, err := check(&current[i]); done {
+					return true, err
+				}
+			}
+			return watchPodsUntil(ctx, watcher, selected, timeoutCh, check)
+		}()
+		if done {
+			return err
+		}
+	}
+}
+
+// watchPodsUntil feeds the events of the selected pods to check until it is
+// done. It returns done=false when the watch channel closes.
+func watchPodsUntil(ctx context.Context, watcher watch.Interface, selected func(*corev1.Pod) bool,
+	timeoutCh *<-chan time.Time, check func(*corev1.Pod) (bool, error)) (bool, error) {
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return false, nil
+			}
+			if event.Type == watch.Error {
+				return true, fmt.Errorf("watch error: %v", event.Object)
+			}
+			pod, ok := event.Object.(*corev1.Pod)
+			if !ok || !selected(pod) {
+				continue
+			}
+			if event.Type == watch.Deleted {
+				return true, fmt.Errorf("pod %s was deleted while waiting for it", pod.Name)
+			}
+			if done, err := check(pod); done {
+				return true, err
+			}
+		case <-*timeoutCh:
+			return true, errWaitTimeout
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+}
+
+// podSelector returns whether a pod matches the label and field selectors of
+// options. metadata.name and metadata.namespace are the only fields supported.
+func podSelector(options metav1.ListOptions) (func(*corev1.Pod) bool, error) {
+	labelSelector, err := labels.Parse(options.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	fieldSelector, err := fields.ParseSelector(options.FieldSelector)
+	if err != nil {
+		return nil, err
+	}
+	return func(pod *corev1.Pod) bool {
+		return labelSelector.Matches(labels.Set(pod.Labels)) &&
+			fieldSelector.Matches(fields.Set{"metadata.name": pod.Name, "metadata.namespace": pod.Namespace})
+	}, nil
+}
@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDiagnosePod(t *testing.T) {
+	waiting := func(reason string) corev1.PodStatus {
+		return corev1.PodStatus{
+			Phase: corev1.PodPending,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "aws-cli",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: "details"}},
+			}},
+		}
+	}
+	tests := []struct {
+		name    string
+		status  corev1.PodStatus
+		running bool
+		failure StartFailure
+	}{
+		{name: "running", status: corev1.PodStatus{Phase: corev1.PodRunning}, running: true},
+		{name: "pending", status: waiting("ContainerCreating")},
+		{name: "image pull back-off", status: waiting("ImagePullBackOff"), failure: StartImagePull},
+		{name: "invalid image", status: waiting("InvalidImageName"), failure: StartImagePull},
+		{name: "crash loop", status: waiting("CrashLoopBackOff"), failure: StartCrashed},
+		{name: "missing secret", status: waiting("CreateContainerConfigError"), failure: StartCrashed},
+		{
+			name: "unschedulable",
+			status: corev1.PodStatus{Phase: corev1.PodPending, Conditions: []corev1.PodCondition{{
+				Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable,
+				Message: "0/3 nodes are available: 3 Insufficient cpu.",
+			}}},
+			failure: StartUnschedulable,
+		},
+		{
+			name: "failed",
+			status: corev1.PodStatus{Phase: corev1.PodFailed, ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "aws-cli",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 127}},
+			}}},
+			failure: StartPodTerminated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			running, issue := diagnosePod(&corev1.Pod{Status: tt.status})
+			assert.Equal(t, tt.running, running)
+			if tt.failure == "" {
+				assert.Nil(t, issue)
+				return
+			}
+			if assert.NotNil(t, issue) {
+				assert.Equal(t, tt.failure, issue.Failure)
+			}
+		})
+	}
+}
+
+func TestWaitForPodRunningFailures(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	namespace, podName := "test-namespace", "test-pod"
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: namespace}}
+	_, err := clientset.CoreV1().Pods(namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	// An unschedulable pod is reported once the timeout expires.
+	pod.Status.Conditions = []corev1.PodCondition{{
+		Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable,
+		Message: "exceeded quota",
+	}}
+	_, err = clientset.CoreV1().Pods(namespace).UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	err = waitForPodRunning(context.Background(), clientset.CoreV1(), namespace, podName, 500*time.Millisecond)
+	var startErr *PodStartError
+	if assert.True(t, errors.As(err, &startErr)) {
+		assert.True(t, startErr.TimedOut)
+		assert.Equal(t, StartUnschedulable, startErr.Failure)
+		assert.Contains(t, startErr.Error(), "exceeded quota")
+	}
+
+	// An image pull failure is reported as soon as it is seen.
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  "aws-cli",
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull"}},
+		}}
+		_, err := clientset.CoreV1().Pods(namespace).UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+	}()
+	err = waitForPodRunning(context.Background(), clientset.CoreV1(), namespace, podName, 0)
+	if assert.True(t, errors.As(err, &startErr)) {
+		assert.False(t, startErr.TimedOut)
+		assert.Equal(t, StartImagePull, startErr.Failure)
+		assert.Equal(t, "ErrImagePull", startErr.Reason)
+	}
+}